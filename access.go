@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	authorization_v1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// period of access checks, role changes are shown within it
+	accessInterval = time.Minute
+	// timeout of access reviews in one namespace, access reviews of every
+	// rule are slowed down by client rate limit
+	accessTimeout = 30 * time.Second
+)
+
+// accessRule is verb on resource kad uses
+type accessRule struct {
+	Verb        string `json:"verb"`
+	Group       string `json:"group,omitempty"`
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource,omitempty"`
+}
+
+// name returns resource with subresource, e.g. pods/log
+func (a accessRule) name() string {
+	if a.Subresource != "" {
+		return a.Resource + "/" + a.Subresource
+	}
+
+	return a.Resource
+}
+
+// allowedBy reports if resource rule allows verb on every resource of kind,
+// rules limited to resource names don't
+func (a accessRule) allowedBy(rule authorization_v1.ResourceRule) bool {
+	if len(rule.ResourceNames) > 0 {
+		return false
+	}
+
+	resource := false
+	for _, r := range rule.Resources {
+		// resource/* and */subresource match only subresources
+		if r == "*" || r == a.name() ||
+			(a.Subresource != "" && (r == a.Resource+"/*" || r == "*/"+a.Subresource)) {
+			resource = true
+			break
+		}
+	}
+
+	return resource &&
+		(contains(rule.Verbs, "*") || contains(rule.Verbs, a.Verb)) &&
+		(contains(rule.APIGroups, "*") || contains(rule.APIGroups, a.Group))
+}
+
+// accessRules are checked with SelfSubjectAccessReview, secrets are never
+// used and show how far access of role goes, other kinds are watched only
+// when they are allowed
+var accessRules = append([]accessRule{
+	{Verb: "list", Resource: "pods"},
+	{Verb: "watch", Resource: "pods"},
+	{Verb: "get", Resource: "pods"},
+	{Verb: "delete", Resource: "pods"},
+	{Verb: "get", Resource: "pods", Subresource: "log"},
+	{Verb: "list", Resource: "services"},
+	{Verb: "watch", Resource: "services"},
+	{Verb: "get", Resource: "services"},
+	{Verb: "delete", Resource: "services"},
+	{Verb: "list", Group: "apps", Resource: "deployments"},
+	{Verb: "watch", Group: "apps", Resource: "deployments"},
+	{Verb: "get", Group: "apps", Resource: "deployments"},
+	{Verb: "delete", Group: "apps", Resource: "deployments"},
+	{Verb: "patch", Group: "apps", Resource: "deployments"},
+	{Verb: "update", Group: "apps", Resource: "deployments", Subresource: "scale"},
+	{Verb: "list", Group: "apps", Resource: "replicasets"},
+	{Verb: "watch", Group: "apps", Resource: "replicasets"},
+	{Verb: "get", Group: "apps", Resource: "replicasets"},
+	{Verb: "delete", Group: "apps", Resource: "replicasets"},
+	{Verb: "list", Resource: "events"},
+	{Verb: "watch", Resource: "events"},
+	{Verb: "list", Group: "discovery.k8s.io", Resource: "endpointslices"},
+	{Verb: "get", Group: "coordination.k8s.io", Resource: "leases"},
+	{Verb: "create", Group: "coordination.k8s.io", Resource: "leases"},
+	{Verb: "update", Group: "coordination.k8s.io", Resource: "leases"},
+	{Verb: "list", Resource: "secrets"},
+	{Verb: "get", Resource: "secrets"},
+	{Verb: "delete", Resource: "secrets"},
+}, kindAccessRules()...)
+
+// accessResult is result of access review of one rule in namespace
+type accessResult struct {
+	accessRule
+	Namespace string `json:"namespace"`
+	Allowed   bool   `json:"allowed"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Access is result of last access check
+type Access struct {
+	Checked time.Time      `json:"checked"`
+	Error   string         `json:"error,omitempty"`
+	Results []accessResult `json:"results"`
+}
+
+// Result returns result of verb on resource in namespace or nil when it
+// wasn't checked
+func (a Access) Result(ns, verb, resource string) *accessResult {
+	for i, r := range a.Results {
+		if r.Namespace == ns && r.Verb == verb && r.name() == resource {
+			return &a.Results[i]
+		}
+	}
+
+	return nil
+}
+
+// Can reports if verb on resource in namespace is allowed, actions are
+// allowed until access is checked
+func (a Access) Can(ns, verb, resource string) bool {
+	if r := a.Result(ns, verb, resource); r != nil {
+		return r.Allowed
+	}
+
+	return true
+}
+
+// Namespaces returns checked namespaces
+func (a Access) Namespaces() []string {
+	n := []string{}
+	for _, r := range a.Results {
+		if !contains(n, r.Namespace) {
+			n = append(n, r.Namespace)
+		}
+	}
+
+	return n
+}
+
+// Verbs returns checked verbs in order of accessRules
+func (a Access) Verbs() []string {
+	v := []string{}
+	for _, r := range a.Results {
+		if !contains(v, r.Verb) {
+			v = append(v, r.Verb)
+		}
+	}
+
+	return v
+}
+
+// Resources returns checked resources in order of accessRules
+func (a Access) Resources() []string {
+	res := []string{}
+	for _, r := range a.Results {
+		if !contains(res, r.name()) {
+			res = append(res, r.name())
+		}
+	}
+
+	return res
+}
+
+// Denied returns rules which are not allowed, e.g. list pods in kad
+func (a Access) Denied() []string {
+	d := []string{}
+	for _, r := range a.Results {
+		if !r.Allowed {
+			d = append(d, r.Verb+" "+r.name()+" in "+r.Namespace)
+		}
+	}
+
+	return d
+}
+
+// equal reports if checks have same results
+func (a Access) equal(b Access) bool {
+	if a.Error != b.Error || len(a.Results) != len(b.Results) {
+		return false
+	}
+	for i := range a.Results {
+		if a.Results[i] != b.Results[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// accessCache holds result of last access check
+type accessCache struct {
+	sync.RWMutex
+	access Access
+	// check is requested before interval elapses
+	recheck chan struct{}
+}
+
+var access = &accessCache{recheck: make(chan struct{}, 1)}
+
+// refresh requests access check, e.g. when namespace is watched
+func (c *accessCache) refresh() {
+	select {
+	case c.recheck <- struct{}{}:
+	default:
+	}
+}
+
+func (c *accessCache) get() Access {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.access
+}
+
+// set stores access and reports if it changed
+func (c *accessCache) set(a Access) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	changed := !c.access.equal(a)
+	c.access = a
+
+	return changed
+}
+
+// checkAccess checks every rule in every namespace
+func checkAccess(ctx context.Context, cs *kubernetes.Clientset, namespaces []string) (Access, error) {
+	ctx, span := tracer.Start(ctx, "check-k8s-access")
+	defer span.End()
+
+	a := Access{Checked: time.Now()}
+
+	for _, ns := range namespaces {
+		results, err := checkNamespaceAccess(ctx, cs, ns)
+		if err != nil {
+			span.RecordError(err)
+			return a, err
+		}
+		a.Results = append(a.Results, results...)
+	}
+
+	return a, nil
+}
+
+// checkNamespaceAccess checks every rule in namespace with single
+// SelfSubjectRulesReview, SelfSubjectAccessReview of every rule is used when
+// authorizer can't list all rules
+func checkNamespaceAccess(ctx context.Context, cs *kubernetes.Clientset, ns string) ([]accessResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, accessTimeout)
+	defer cancel()
+
+	results := []accessResult{}
+
+	ssrr := &authorization_v1.SelfSubjectRulesReview{
+		Spec: authorization_v1.SelfSubjectRulesReviewSpec{Namespace: ns},
+	}
+	rr, err := cs.AuthorizationV1().SelfSubjectRulesReviews().Create(ctx, ssrr, metav1.CreateOptions{})
+	if err == nil && !rr.Status.Incomplete {
+		for _, r := range accessRules {
+			res := accessResult{accessRule: r, Namespace: ns, Reason: "not allowed by any rule"}
+			for _, rule := range rr.Status.ResourceRules {
+				if r.allowedBy(rule) {
+					res.Allowed, res.Reason = true, ""
+					break
+				}
+			}
+			results = append(results, res)
+		}
+
+		return results, nil
+	}
+
+	for _, r := range accessRules {
+		ssar := &authorization_v1.SelfSubjectAccessReview{
+			Spec: authorization_v1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorization_v1.ResourceAttributes{
+					Namespace:   ns,
+					Verb:        r.Verb,
+					Group:       r.Group,
+					Resource:    r.Resource,
+					Subresource: r.Subresource,
+				},
+			},
+		}
+
+		res, err := cs.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, ssar, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("Unable to review %s %s in %s: %s", r.Verb, r.name(), ns, err)
+		}
+
+		reason := res.Status.Reason
+		if e := res.Status.EvaluationError; e != "" {
+			reason = strings.TrimSpace(reason + " " + e)
+		}
+
+		results = append(results, accessResult{
+			accessRule: r,
+			Namespace:  ns,
+			Allowed:    res.Status.Allowed,
+			Reason:     reason,
+		})
+	}
+
+	return results, nil
+}
+
+// watchAccess checks access in watched namespaces periodically until ctx is
+// done, page is updated when result changes
+func watchAccess(ctx context.Context) {
+	for {
+		a := Access{Checked: time.Now()}
+
+		cs, err := getClientset()
+		if err == nil {
+			a, err = checkAccess(ctx, cs, k8sCache.watched())
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			a.Error = err.Error()
+			a.Results = nil
+		}
+
+		if access.set(a) {
+			switch d := a.Denied(); {
+			case err != nil:
+				log.Printf("Unable to check kubernetes access: %s", err)
+			case len(d) > 0:
+				log.Printf("Kubernetes access denied for %s", strings.Join(d, ", "))
+			default:
+				log.Printf("Kubernetes access allowed for all %d checks", len(a.Results))
+			}
+			events.notify()
+		}
+		k8sCache.enableKinds(a)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-access.recheck:
+		case <-time.After(accessInterval):
+		}
+	}
+}
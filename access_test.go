@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	authorization_v1 "k8s.io/api/authorization/v1"
+)
+
+func TestAccessRuleAllowedBy(t *testing.T) {
+	pods := accessRule{Verb: "delete", Resource: "pods"}
+	logs := accessRule{Verb: "get", Resource: "pods", Subresource: "log"}
+	scale := accessRule{Verb: "update", Group: "apps", Resource: "deployments", Subresource: "scale"}
+
+	tests := []struct {
+		name    string
+		rule    accessRule
+		allowed authorization_v1.ResourceRule
+		ok      bool
+	}{
+		{
+			name:    "exact",
+			rule:    pods,
+			allowed: authorization_v1.ResourceRule{Verbs: []string{"get", "delete"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+			ok:      true,
+		},
+		{
+			name:    "other verb",
+			rule:    pods,
+			allowed: authorization_v1.ResourceRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+		},
+		{
+			name:    "wildcards",
+			rule:    scale,
+			allowed: authorization_v1.ResourceRule{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+			ok:      true,
+		},
+		{
+			name:    "other group",
+			rule:    scale,
+			allowed: authorization_v1.ResourceRule{Verbs: []string{"update"}, APIGroups: []string{""}, Resources: []string{"deployments/scale"}},
+		},
+		{
+			name:    "resource names",
+			rule:    pods,
+			allowed: authorization_v1.ResourceRule{Verbs: []string{"delete"}, APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"kad"}},
+		},
+		{
+			name:    "subresource",
+			rule:    logs,
+			allowed: authorization_v1.ResourceRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods/log"}},
+			ok:      true,
+		},
+		{
+			name:    "resource doesn't match subresource",
+			rule:    logs,
+			allowed: authorization_v1.ResourceRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+		},
+		{
+			name:    "all subresources",
+			rule:    logs,
+			allowed: authorization_v1.ResourceRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods/*"}},
+			ok:      true,
+		},
+		{
+			name:    "subresource of all resources",
+			rule:    logs,
+			allowed: authorization_v1.ResourceRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"*/log"}},
+			ok:      true,
+		},
+		{
+			name:    "subresource wildcard doesn't match resource",
+			rule:    pods,
+			allowed: authorization_v1.ResourceRule{Verbs: []string{"delete"}, APIGroups: []string{""}, Resources: []string{"pods/*"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok := tt.rule.allowedBy(tt.allowed); ok != tt.ok {
+				t.Errorf("Expected %t, got %t", tt.ok, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// info is JSON view of page content
+type info struct {
+	Hostname   string         `json:"hostname"`
+	RemoteAddr string         `json:"remoteAddr"`
+	Ready      bool           `json:"ready"`
+	Hits       int            `json:"hits"`
+	Redis      infoRedis      `json:"redis"`
+	Vars       []envVar       `json:"vars"`
+	Headers    []Header       `json:"headers"`
+	Config     infoConfig     `json:"config"`
+	Kubernetes infoKubernetes `json:"kubernetes"`
+	Files      []dataFile     `json:"files"`
+	Faults     []fault        `json:"faults"`
+	Chaos      chaosStatus    `json:"chaos"`
+	Leader     leaderStatus   `json:"leader"`
+	Instance   instanceInfo   `json:"instance"`
+	Load       []loadJob      `json:"load"`
+	Memory     memoryStatus   `json:"memory"`
+	Disk       diskStatus     `json:"disk"`
+	Clients    []clientHealth `json:"clients"`
+	// secret values are not masked
+	Revealed bool `json:"revealed"`
+}
+
+type infoRedis struct {
+	Host  string `json:"host"`
+	Path  string `json:"path,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type infoConfig struct {
+	Cmd                string        `json:"cmd"`
+	Color              string        `json:"color"`
+	FailureProbability float64       `json:"failureProbability"`
+	File               string        `json:"file"`
+	Content            string        `json:"content"`
+	Settings           []configValue `json:"settings"`
+	ReloadError        string        `json:"reloadError,omitempty"`
+	Reloaded           *time.Time    `json:"reloaded,omitempty"`
+}
+
+type infoKubernetes struct {
+	Host      string    `json:"host"`
+	Namespace string    `json:"namespace"`
+	Synced    bool      `json:"synced"`
+	Error     string    `json:"error,omitempty"`
+	Resources Resources `json:"resources"`
+	Access    Access    `json:"access"`
+}
+
+// newInfo converts page content into its JSON view
+func newInfo(pc pageContent) info {
+	i := info{
+		Hostname:   pc.Hostname,
+		RemoteAddr: pc.RemoteAddr,
+		Ready:      pc.Ready,
+		Hits:       pc.Hits,
+		Redis: infoRedis{
+			Host:  pc.RedisHost,
+			Path:  pc.RedisPath,
+			Error: pc.RedisError,
+		},
+		Vars:    []envVar{},
+		Headers: pc.Headers,
+		Config: infoConfig{
+			Cmd:                pc.Cmd,
+			Color:              pc.Color,
+			FailureProbability: pc.FailureProbability,
+			File:               pc.ConfigFilePath,
+			Content:            pc.ConfFile,
+			Settings:           pc.Settings,
+			ReloadError:        pc.ConfigReloadError,
+		},
+		Kubernetes: infoKubernetes{
+			Host:      pc.KubernetesHost,
+			Namespace: pc.Namespace,
+			Synced:    pc.KubernetesSynced,
+			Error:     pc.KubernetesError,
+			Resources: pc.Resources,
+			Access:    pc.Access,
+		},
+		Files:    pc.PersistentFiles,
+		Faults:   pc.Faults,
+		Chaos:    pc.Chaos,
+		Leader:   pc.Leader,
+		Instance: newInstanceInfo(pc),
+		Load:     pc.LoadJobs,
+		Memory:   pc.Memory,
+		Disk:     pc.Disk,
+		Clients:  pc.Clients,
+		Revealed: pc.Revealed,
+	}
+
+	for _, v := range pc.Vars {
+		i.Vars = append(i.Vars, *v)
+	}
+	sort.Slice(i.Vars, func(a, b int) bool {
+		return i.Vars[a].Name < i.Vars[b].Name
+	})
+
+	if !pc.ConfigReloaded.IsZero() {
+		i.Config.Reloaded = &pc.ConfigReloaded
+	}
+
+	return i
+}
+
+// sections returns parts of info served as sub-resources
+func (i info) sections() map[string]interface{} {
+	return map[string]interface{}{
+		"hostname":   i.Hostname,
+		"remoteAddr": i.RemoteAddr,
+		"ready":      i.Ready,
+		"hits":       i.Hits,
+		"redis":      i.Redis,
+		"vars":       i.Vars,
+		"headers":    i.Headers,
+		"config":     i.Config,
+		"kubernetes": i.Kubernetes,
+		"files":      i.Files,
+		"faults":     i.Faults,
+		"chaos":      i.Chaos,
+		"leader":     i.Leader,
+		"instance":   i.Instance,
+		"load":       i.Load,
+		"memory":     i.Memory,
+		"disk":       i.Disk,
+		"clients":    i.Clients,
+	}
+}
+
+// wantsJSON returns true when Accept header prefers JSON over HTML
+func wantsJSON(r *http.Request) bool {
+	var htmlQ, jsonQ float64
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if pq, err := strconv.ParseFloat(v, 64); err == nil {
+				q = pq
+			}
+		}
+
+		switch mt {
+		case "application/json":
+			jsonQ = q
+		case "text/html", "*/*":
+			if q > htmlQ {
+				htmlQ = q
+			}
+		}
+	}
+
+	return jsonQ > 0 && jsonQ > htmlQ
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Unable to encode JSON response: %s", err)
+	}
+}
+
+// infoHandler returns page content as JSON, page hit isn't counted
+//
+//	GET /api/v1/info            everything shown on page
+//	GET /api/v1/info/{section}  one section, e.g. hits, vars or kubernetes
+func infoHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "info")
+	defer span.End()
+
+	i := newInfo(readPage(ctx, r, false))
+
+	section, ok := mux.Vars(r)["section"]
+	if !ok {
+		writeJSON(w, i)
+		return
+	}
+
+	v, ok := i.sections()[section]
+	if !ok {
+		http.Error(w, "Unknown section "+section, http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, v)
+}
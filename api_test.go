@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWantsJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		json   bool
+	}{
+		{accept: "", json: false},
+		{accept: "application/json", json: true},
+		{accept: "text/html", json: false},
+		{accept: "*/*", json: false},
+		{accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", json: false},
+		{accept: "application/json, text/html;q=0.9", json: true},
+		{accept: "text/html, application/json;q=0.9", json: false},
+		{accept: "application/json;q=0.5, */*;q=0.1", json: true},
+		{accept: "application/json;q=0", json: false},
+		{accept: "application/json;q=1, text/html;q=1", json: false},
+		{accept: "invalid;;, application/json", json: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept", tt.accept)
+
+			if j := wantsJSON(r); j != tt.json {
+				t.Errorf("Expected %t, got %t", tt.json, j)
+			}
+		})
+	}
+}
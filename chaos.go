@@ -0,0 +1,454 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	defaultChaosInterval = 5 * time.Minute
+	minChaosInterval     = 5 * time.Second
+	// rounds per minute, one round per second at most
+	maxChaosRate = 60
+
+	// kills and skipped rounds kept in audit trail
+	chaosAuditSize = 50
+	// timeout of listing and deleting pods in one round
+	chaosRoundTimeout = 30 * time.Second
+)
+
+// results of chaos audit entries
+const (
+	chaosKilled  = "killed"
+	chaosDryRun  = "dry-run"
+	chaosFailed  = "failed"
+	chaosSkipped = "skipped"
+)
+
+// chaosConfig describes which pods are killed and how often
+type chaosConfig struct {
+	Enabled       bool   `json:"enabled"`
+	Namespace     string `json:"namespace"`
+	LabelSelector string `json:"labelSelector"`
+	// rounds run every interval or at random times with average rate of
+	// rounds per minute
+	Interval string  `json:"interval,omitempty"`
+	Rate     float64 `json:"rate,omitempty"`
+	// pods killed in one round, percent of matching pods when set
+	Count   int     `json:"count,omitempty"`
+	Percent float64 `json:"percent,omitempty"`
+	// ready pods matching selector left after round
+	MinAvailable int  `json:"minAvailable"`
+	DryRun       bool `json:"dryRun"`
+
+	interval time.Duration
+}
+
+func (c *chaosConfig) validate() error {
+	if c.Namespace == "" {
+		return fmt.Errorf("Missing namespace")
+	}
+	// empty selector matches every pod in namespace, kad itself included
+	sel, err := labels.Parse(c.LabelSelector)
+	if err != nil {
+		return fmt.Errorf("Invalid label selector %s: %s", c.LabelSelector, err)
+	}
+	if sel.Empty() {
+		return fmt.Errorf("Missing label selector")
+	}
+
+	if c.Rate < 0 || c.Rate > maxChaosRate {
+		return fmt.Errorf("Rate must be between 0 and %d rounds per minute", maxChaosRate)
+	}
+	if c.Rate > 0 && c.Interval != "" {
+		return fmt.Errorf("Set either interval or rate")
+	}
+	if c.Rate == 0 {
+		c.interval = defaultChaosInterval
+		if c.Interval != "" {
+			d, err := time.ParseDuration(c.Interval)
+			if err != nil || d < minChaosInterval {
+				return fmt.Errorf("Interval must be at least %s", minChaosInterval)
+			}
+			c.interval = d
+		}
+	}
+
+	if c.Count < 0 {
+		return fmt.Errorf("Count must not be negative")
+	}
+	if c.Percent < 0 || c.Percent > 100 {
+		return fmt.Errorf("Percent must be between 0 and 100")
+	}
+	if c.Count > 0 && c.Percent > 0 {
+		return fmt.Errorf("Set either count or percent")
+	}
+	if c.Count == 0 && c.Percent == 0 {
+		c.Count = 1
+	}
+	if c.MinAvailable < 0 {
+		return fmt.Errorf("Min available must not be negative")
+	}
+
+	return nil
+}
+
+// wait returns time until next round, it's random with rate
+func (c chaosConfig) wait() time.Duration {
+	if c.Rate > 0 {
+		return time.Duration(rand.ExpFloat64() / c.Rate * float64(time.Minute))
+	}
+
+	return c.interval
+}
+
+// victims returns number of pods killed out of n matching pods
+func (c chaosConfig) victims(n int) int {
+	if c.Percent > 0 {
+		return int(math.Ceil(float64(n) * c.Percent / 100))
+	}
+
+	return c.Count
+}
+
+// String describes schedule, e.g. 1 pod app=kad every 5m0s
+func (c chaosConfig) String() string {
+	what := fmt.Sprintf("%d pods", c.Count)
+	if c.Percent > 0 {
+		what = fmt.Sprintf("%g%% of pods", c.Percent)
+	}
+	if c.LabelSelector != "" {
+		what += " matching " + c.LabelSelector
+	}
+
+	when := "every " + c.interval.String()
+	if c.Rate > 0 {
+		when = fmt.Sprintf("%g times per minute on average", c.Rate)
+	}
+
+	return fmt.Sprintf("%s in %s %s, keeping %d ready", what, c.Namespace, when, c.MinAvailable)
+}
+
+// chaosEvent is entry of audit trail, pod is empty for skipped rounds
+type chaosEvent struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod,omitempty"`
+	Result    string    `json:"result"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// chaosStatus is state of chaos loop shown on page
+type chaosStatus struct {
+	Running bool `json:"running"`
+	// loop is started from config file or admin API with client address
+	Source  string      `json:"source,omitempty"`
+	Config  chaosConfig `json:"config"`
+	Started time.Time   `json:"started"`
+	Next    time.Time   `json:"next"`
+	// rounds are skipped on followers when leader is elected
+	Follower bool         `json:"follower"`
+	Leader   string       `json:"leader,omitempty"`
+	Audit    []chaosEvent `json:"audit"`
+}
+
+// chaosLoop kills pods on background
+type chaosLoop struct {
+	sync.Mutex
+	status chaosStatus
+	cancel context.CancelFunc
+}
+
+var chaos = &chaosLoop{status: chaosStatus{Audit: []chaosEvent{}}}
+
+// configure applies chaos section of config file, it's called only when the
+// section changes so loop started from admin API isn't stopped by unrelated
+// reload
+func (c *chaosLoop) configure(cfg chaosConfig) {
+	if cfg.Enabled {
+		c.start(cfg, "config")
+		return
+	}
+
+	c.stop()
+}
+
+// start runs loop with config, running loop is replaced
+func (c *chaosLoop) start(cfg chaosConfig, source string) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.Lock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	cfg.Enabled = true
+	c.cancel = cancel
+	c.status.Running = true
+	c.status.Source = source
+	c.status.Config = cfg
+	c.status.Started = time.Now()
+	c.status.Next = time.Time{}
+	c.Unlock()
+
+	chaosRunning.Set(1)
+	log.Printf("Chaos started from %s: killing %s%s", source, cfg, dryRunNote(cfg.DryRun))
+	events.notify()
+
+	go c.run(ctx, cfg)
+}
+
+// stop cancels running loop
+func (c *chaosLoop) stop() {
+	c.Lock()
+	running := c.status.Running
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+	c.status.Running = false
+	c.status.Config.Enabled = false
+	c.status.Next = time.Time{}
+	c.Unlock()
+
+	if running {
+		chaosRunning.Set(0)
+		log.Printf("Chaos stopped")
+		events.notify()
+	}
+}
+
+// get returns copy of status with audit trail
+func (c *chaosLoop) get() chaosStatus {
+	c.Lock()
+	defer c.Unlock()
+
+	s := c.status
+	s.Audit = append([]chaosEvent{}, c.status.Audit...)
+	if !leader.isLeader() {
+		s.Follower, s.Leader = true, leader.get().Holder
+	}
+
+	return s
+}
+
+func (c *chaosLoop) setNext(t time.Time) {
+	c.Lock()
+	c.status.Next = t
+	c.Unlock()
+
+	events.notify()
+}
+
+// record adds event to audit trail, newest first
+func (c *chaosLoop) record(e chaosEvent) {
+	e.Time = time.Now()
+
+	c.Lock()
+	c.status.Audit = append([]chaosEvent{e}, c.status.Audit...)
+	if len(c.status.Audit) > chaosAuditSize {
+		c.status.Audit = c.status.Audit[:chaosAuditSize]
+	}
+	c.Unlock()
+
+	if e.Pod != "" {
+		chaosKills.WithLabelValues(e.Namespace, e.Result).Inc()
+		log.Printf("Chaos %s pod %s/%s%s", e.Result, e.Namespace, e.Pod, messageNote(e.Message))
+	} else {
+		log.Printf("Chaos round %s in %s: %s", e.Result, e.Namespace, e.Message)
+	}
+	events.notify()
+}
+
+// run kills pods until ctx is done
+func (c *chaosLoop) run(ctx context.Context, cfg chaosConfig) {
+	for {
+		d := cfg.wait()
+		c.setNext(time.Now().Add(d))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d):
+		}
+
+		// skipped silently, audit trail of leader shows kills
+		if leader.isLeader() {
+			c.round(ctx, cfg)
+		}
+	}
+}
+
+// round kills pods matching selector, pods are checked against guard of
+// actions same as delete from page
+func (c *chaosLoop) round(ctx context.Context, cfg chaosConfig) {
+	ctx, cancel := context.WithTimeout(ctx, chaosRoundTimeout)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "chaos-round")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("resources.namespace", cfg.Namespace),
+		attribute.String("chaos.selector", cfg.LabelSelector),
+		attribute.Bool("chaos.dry_run", cfg.DryRun),
+	)
+
+	ns := cfg.Namespace
+	skip := func(format string, a ...interface{}) {
+		c.record(chaosEvent{Namespace: ns, Result: chaosSkipped, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if !k8sCache.watching(ns) {
+		skip("namespace %s is not watched", ns)
+		return
+	}
+
+	a := state.config().Actions
+	if err := a.typeAllowed("pod"); err != nil {
+		skip("%s", err)
+		return
+	}
+
+	cs, err := getClientset()
+	if err != nil {
+		skip("can't connect to kubernetes: %s", err)
+		return
+	}
+
+	pods, err := cs.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: cfg.LabelSelector})
+	if err != nil {
+		span.RecordError(err)
+		skip("unable to list pods: %s", err)
+		return
+	}
+
+	victims, why := cfg.selectVictims(pods.Items, a)
+	if len(victims) == 0 {
+		skip("%s", why)
+		return
+	}
+
+	pk, _ := resourceKindByName("pod")
+	for _, p := range victims {
+		e := chaosEvent{Namespace: ns, Pod: p.Name, Result: chaosKilled}
+
+		if cfg.DryRun {
+			e.Result = chaosDryRun
+		} else if err := pk.delete(ctx, cs, ns, p.Name, metav1.DeleteOptions{}); err != nil {
+			span.RecordError(err)
+			e.Result, e.Message = chaosFailed, err.Error()
+		}
+
+		c.record(e)
+	}
+}
+
+// selectVictims picks random pods allowed by actions guard, ready pods are
+// never reduced below min available. Reason is returned when no pod is picked.
+func (c chaosConfig) selectVictims(pods []v1.Pod, a actionsConfig) ([]v1.Pod, string) {
+	ready := 0
+	candidates := []v1.Pod{}
+	for _, p := range pods {
+		if p.DeletionTimestamp != nil {
+			continue
+		}
+		if isPodReady(p) {
+			ready++
+		}
+		if a.labelsAllowed(p.Labels) == nil {
+			candidates = append(candidates, p)
+		}
+	}
+
+	n := c.victims(len(candidates))
+	if n > ready-c.MinAvailable {
+		n = ready - c.MinAvailable
+	}
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	if n <= 0 {
+		return nil, fmt.Sprintf("%d of %d pods ready, %d allowed by actions guard, min available is %d", ready, len(pods), len(candidates), c.MinAvailable)
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	return candidates[:n], ""
+}
+
+// isPodReady reports if pod has Ready condition
+func isPodReady(p v1.Pod) bool {
+	for _, c := range p.Status.Conditions {
+		if c.Type == v1.PodReady {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+func messageNote(msg string) string {
+	if msg != "" {
+		return ": " + msg
+	}
+
+	return ""
+}
+
+func dryRunNote(dryRun bool) string {
+	if dryRun {
+		return " (dry run)"
+	}
+
+	return ""
+}
+
+// chaosHandler manages chaos loop
+//
+//	GET    /chaos  show status and audit trail
+//	PUT    /chaos  start loop, config is sent as JSON body, e.g.
+//	               {"labelSelector": "app=kad", "interval": "1m", "minAvailable": 1}
+//	DELETE /chaos  stop loop
+func chaosHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPut:
+		cfg := chaosConfig{Namespace: state.config().Namespace}
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			http.Error(w, "Unable to parse chaos config: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := cfg.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !k8sCache.watching(cfg.Namespace) {
+			http.Error(w, fmt.Sprintf("Namespace %s is not watched", cfg.Namespace), http.StatusForbidden)
+			return
+		}
+
+		chaos.start(cfg, "api "+r.RemoteAddr)
+
+	case http.MethodDelete:
+		chaos.stop()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(chaos.get()); err != nil {
+		log.Printf("Unable to encode chaos status: %s", err)
+	}
+}
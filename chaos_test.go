@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// testPods returns ready pods with app=kad label followed by pods which
+// aren't ready
+func testPods(ready, notReady int) []v1.Pod {
+	pods := []v1.Pod{}
+	for i := 0; i < ready+notReady; i++ {
+		status := v1.ConditionTrue
+		if i >= ready {
+			status = v1.ConditionFalse
+		}
+		pods = append(pods, v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("kad-%d", i), Labels: map[string]string{"app": "kad"}},
+			Status:     v1.PodStatus{Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: status}}},
+		})
+	}
+
+	return pods
+}
+
+func TestSelectVictims(t *testing.T) {
+	terminating := testPods(3, 0)
+	terminating[0].DeletionTimestamp = &metav1.Time{}
+
+	protected := testPods(4, 0)
+	protected[0].Labels = map[string]string{"app": "kad", "chaos": "off"}
+	protected[1].Labels = map[string]string{"app": "kad", "chaos": "off"}
+
+	tests := []struct {
+		name     string
+		cfg      chaosConfig
+		pods     []v1.Pod
+		selector string
+		victims  int
+	}{
+		{name: "count", cfg: chaosConfig{Count: 1, MinAvailable: 1}, pods: testPods(3, 0), victims: 1},
+		{name: "count limited by min available", cfg: chaosConfig{Count: 5, MinAvailable: 1}, pods: testPods(3, 0), victims: 2},
+		{name: "min available reached", cfg: chaosConfig{Count: 1, MinAvailable: 3}, pods: testPods(3, 0), victims: 0},
+		{name: "min available above ready", cfg: chaosConfig{Count: 1, MinAvailable: 4}, pods: testPods(3, 0), victims: 0},
+		{name: "zero min available", cfg: chaosConfig{Count: 5}, pods: testPods(3, 0), victims: 3},
+		{name: "pods which aren't ready don't count", cfg: chaosConfig{Count: 3, MinAvailable: 1}, pods: testPods(2, 3), victims: 1},
+		{name: "terminating pods are skipped", cfg: chaosConfig{Count: 3, MinAvailable: 1}, pods: terminating, victims: 1},
+		{name: "percent rounds up", cfg: chaosConfig{Percent: 10, MinAvailable: 1}, pods: testPods(5, 0), victims: 1},
+		{name: "percent", cfg: chaosConfig{Percent: 50, MinAvailable: 1}, pods: testPods(6, 0), victims: 3},
+		{name: "actions guard", cfg: chaosConfig{Count: 4}, pods: protected, selector: "chaos!=off", victims: 2},
+		{name: "no pods", cfg: chaosConfig{Count: 1}, pods: []v1.Pod{}, victims: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := labels.Parse(tt.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a := actionsConfig{AllowedTypes: defaultAllowedTypes, Selector: sel}
+
+			victims, why := tt.cfg.selectVictims(tt.pods, a)
+			if len(victims) != tt.victims {
+				t.Fatalf("Expected %d victims, got %d (%s)", tt.victims, len(victims), why)
+			}
+			if len(victims) == 0 && why == "" {
+				t.Error("Expected reason when no pod is selected")
+			}
+
+			seen := map[string]bool{}
+			for _, p := range victims {
+				if seen[p.Name] {
+					t.Errorf("Pod %s selected twice", p.Name)
+				}
+				seen[p.Name] = true
+
+				if p.DeletionTimestamp != nil {
+					t.Errorf("Terminating pod %s selected", p.Name)
+				}
+				if !sel.Matches(labels.Set(p.Labels)) {
+					t.Errorf("Pod %s not allowed by actions guard selected", p.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = time.Minute
+
+	redisTimeout = 300 * time.Millisecond
+)
+
+// clientHealth describes health of long-lived client
+type clientHealth struct {
+	Name      string    `json:"name"`
+	Healthy   bool      `json:"healthy"`
+	LastError string    `json:"lastError,omitempty"`
+	Since     time.Time `json:"since"`
+	// next connection attempt when unhealthy
+	Retry time.Time `json:"retry,omitempty"`
+}
+
+// backoff tracks client failures and delays reconnect attempts exponentially
+type backoff struct {
+	failures int
+	lastErr  error
+	next     time.Time
+	since    time.Time
+	// single attempt is let through once backoff expires
+	probing bool
+}
+
+// fail records failure and schedules next attempt, failures of concurrent
+// calls in same backoff window are counted once
+func (b *backoff) fail(err error) {
+	b.probing = false
+	b.lastErr = err
+	if b.failures > 0 && time.Now().Before(b.next) {
+		return
+	}
+
+	if b.failures == 0 {
+		b.since = time.Now()
+	}
+	b.failures++
+
+	d := minReconnectBackoff << uint(b.failures-1)
+	if d > maxReconnectBackoff || d <= 0 {
+		d = maxReconnectBackoff
+	}
+	b.next = time.Now().Add(d)
+}
+
+// succeed resets failures
+func (b *backoff) succeed() {
+	if b.failures > 0 || b.since.IsZero() {
+		b.since = time.Now()
+	}
+	b.failures = 0
+	b.lastErr = nil
+	b.next = time.Time{}
+	b.probing = false
+}
+
+// wait returns error when next attempt is not allowed yet, only one attempt
+// is allowed once backoff expires until it succeeds or fails
+func (b *backoff) wait() error {
+	if b.lastErr == nil {
+		return nil
+	}
+	if time.Now().Before(b.next) {
+		return fmt.Errorf("%s (retrying in %s)", b.lastErr, time.Until(b.next).Round(time.Second))
+	}
+	if b.probing {
+		return fmt.Errorf("%s (retrying)", b.lastErr)
+	}
+	b.probing = true
+
+	return nil
+}
+
+func (b *backoff) health(name string) clientHealth {
+	h := clientHealth{Name: name, Healthy: b.failures == 0, Since: b.since}
+	if b.lastErr != nil {
+		h.LastError = b.lastErr.Error()
+		h.Retry = b.next
+	}
+
+	return h
+}
+
+// redisConn is shared redis client, it's recreated when address changes
+type redisConn struct {
+	sync.Mutex
+	addr    string
+	client  *redis.Client
+	backoff backoff
+	// calls using client, replaced client is closed once they finish
+	inflight *sync.WaitGroup
+}
+
+var redisClient = &redisConn{}
+
+// get returns client for addr, done must be called once client isn't used
+func (c *redisConn) get(addr string) (cl *redis.Client, done func()) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.client == nil || c.addr != addr {
+		if c.client != nil {
+			log.Printf("Redis address changed from %s to %s, reconnecting", c.addr, addr)
+
+			old, inflight := c.client, c.inflight
+			go func() {
+				inflight.Wait()
+				old.Close()
+			}()
+		}
+
+		c.addr = addr
+		c.backoff = backoff{}
+		c.inflight = &sync.WaitGroup{}
+		c.client = redis.NewClient(&redis.Options{
+			Addr:         addr,
+			DialTimeout:  redisTimeout,
+			ReadTimeout:  redisTimeout,
+			WriteTimeout: redisTimeout,
+		})
+	}
+
+	c.inflight.Add(1)
+
+	return c.client, c.inflight.Done
+}
+
+// incr increments key
+func (c *redisConn) incr(addr, key string) (int64, error) {
+	return c.do(addr, func(cl *redis.Client) (int64, error) {
+		return cl.Incr(key).Result()
+	})
+}
+
+// value reads integer key, missing key is 0
+func (c *redisConn) value(addr, key string) (int64, error) {
+	return c.do(addr, func(cl *redis.Client) (int64, error) {
+		v, err := cl.Get(key).Int64()
+		if err == redis.Nil {
+			return 0, nil
+		}
+		return v, err
+	})
+}
+
+// do runs command and tracks client health, failing fast while in backoff
+func (c *redisConn) do(addr string, cmd func(*redis.Client) (int64, error)) (int64, error) {
+	cl, done := c.get(addr)
+	defer done()
+
+	c.Lock()
+	err := c.backoff.wait()
+	c.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := cmd(cl)
+
+	c.Lock()
+	defer c.Unlock()
+
+	// result of replaced client doesn't change health of current one
+	if cl != c.client {
+		return v, err
+	}
+
+	if err != nil {
+		c.backoff.fail(err)
+		return 0, err
+	}
+	c.backoff.succeed()
+
+	return v, nil
+}
+
+// stats returns connection pool stats, nil when redis is not used
+func (c *redisConn) stats() *redis.PoolStats {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.client == nil {
+		return nil
+	}
+
+	return c.client.PoolStats()
+}
+
+func (c *redisConn) health() (clientHealth, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.backoff.health("redis " + c.addr), c.client != nil
+}
+
+// kubeConn is shared kubernetes clientset
+type kubeConn struct {
+	sync.Mutex
+	clientset *kubernetes.Clientset
+	backoff   backoff
+}
+
+var kubeClient = &kubeConn{}
+
+// getClientset returns shared clientset, it's created on first use
+func getClientset() (*kubernetes.Clientset, error) {
+	kubeClient.Lock()
+	defer kubeClient.Unlock()
+
+	if kubeClient.clientset != nil {
+		return kubeClient.clientset, nil
+	}
+
+	if err := kubeClient.backoff.wait(); err != nil {
+		return nil, err
+	}
+
+	cs, err := newClientset()
+	if err != nil {
+		kubeClient.backoff.fail(err)
+		return nil, err
+	}
+
+	kubeClient.clientset = cs
+	kubeClient.backoff.succeed()
+
+	return cs, nil
+}
+
+// observe tracks health of kubernetes API from responses
+func (c *kubeConn) observe(err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if err != nil {
+		// requests are not blocked, failures only mark client unhealthy
+		c.backoff.fail(err)
+		c.backoff.next = time.Time{}
+		return
+	}
+	if c.backoff.failures > 0 {
+		c.backoff.succeed()
+	}
+}
+
+func (c *kubeConn) health() (clientHealth, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.backoff.health("kubernetes"), c.clientset != nil || c.backoff.lastErr != nil
+}
+
+// clientsHealth returns health of clients in use
+func clientsHealth() []clientHealth {
+	r := []clientHealth{}
+
+	if h, ok := kubeClient.health(); ok {
+		r = append(r, h)
+	}
+	if h, ok := redisClient.health(); ok {
+		r = append(r, h)
+	}
+
+	return r
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoffBurst(t *testing.T) {
+	b := backoff{}
+
+	// parallel requests failing together are one failure
+	for i := 0; i < 10; i++ {
+		b.fail(fmt.Errorf("timeout"))
+	}
+	if b.failures != 1 {
+		t.Fatalf("Expected 1 failure, got %d", b.failures)
+	}
+	if d := time.Until(b.next); d > minReconnectBackoff {
+		t.Errorf("Expected backoff up to %s, got %s", minReconnectBackoff, d)
+	}
+	if err := b.wait(); err == nil {
+		t.Error("Expected error in backoff window")
+	}
+
+	// one probe is let through once window expires
+	b.next = time.Now().Add(-time.Millisecond)
+	if err := b.wait(); err != nil {
+		t.Errorf("Expected probe, got %s", err)
+	}
+	if err := b.wait(); err == nil {
+		t.Error("Expected error while probe is running")
+	}
+
+	b.fail(fmt.Errorf("timeout"))
+	if b.failures != 2 {
+		t.Errorf("Expected 2 failures after failed probe, got %d", b.failures)
+	}
+
+	b.next = time.Now().Add(-time.Millisecond)
+	if err := b.wait(); err != nil {
+		t.Errorf("Expected probe, got %s", err)
+	}
+	b.succeed()
+	if err := b.wait(); err != nil || b.failures != 0 {
+		t.Errorf("Expected healthy backoff, got %d failures: %v", b.failures, err)
+	}
+}
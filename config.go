@@ -0,0 +1,522 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Config is effective configuration of kad
+//
+// Every value is resolved with following precedence (highest first):
+//  1. command line flag (only when set explicitly)
+//  2. environment variable
+//  3. configuration file
+//  4. default value
+type Config struct {
+	Listen             string
+	ListenAdmin        string
+	Color              string
+	RedisServer        string
+	FailureProbability float64
+	ExitDelay          int
+	ShutdownTimeout    int
+	JaegerAgentHost    string
+	DataDir            string
+	Endpoints          map[string]bool
+
+	// namespace of actions when request doesn't set one
+	Namespace string
+	// watched namespaces, namespaces matching selector are watched too
+	Namespaces        []string
+	NamespaceSelector labels.Selector
+
+	// latency added to every request on client port
+	Latency delay
+	// readiness probe reports not ready when false
+	Ready bool
+	// masking of secret environment variables and headers
+	Masking masking
+	// guard of destructive kubernetes actions
+	Actions actionsConfig
+	// pods killed on background
+	Chaos chaosConfig
+	// election of instance running singleton work
+	LeaderElection leaderConfig
+	// discovery of other replicas
+	Peers peersConfig
+}
+
+// fileConfig is structure of configuration file, all fields are optional
+type fileConfig struct {
+	Listen             *string  `yaml:"listen"`
+	ListenAdmin        *string  `yaml:"listenAdmin"`
+	Color              *string  `yaml:"color"`
+	Namespace          *string  `yaml:"namespace"`
+	Namespaces         []string `yaml:"namespaces"`
+	NamespaceSelector  *string  `yaml:"namespaceSelector"`
+	Redis              *string  `yaml:"redis"`
+	FailureProbability *float64 `yaml:"failureProbability"`
+	ExitDelay          *int     `yaml:"exitDelay"`
+	ShutdownTimeout    *int     `yaml:"shutdownTimeout"`
+	DataDir            *string  `yaml:"dataDir"`
+	Ready              *bool    `yaml:"ready"`
+
+	Tracing struct {
+		JaegerAgentHost *string `yaml:"jaegerAgentHost"`
+	} `yaml:"tracing"`
+
+	Latency struct {
+		Distribution    *string  `yaml:"distribution"`
+		Ms              *float64 `yaml:"ms"`
+		Jitter          *float64 `yaml:"jitter"`
+		TailProbability *float64 `yaml:"tailProbability"`
+		TailMs          *float64 `yaml:"tailMs"`
+	} `yaml:"latency"`
+
+	Masking struct {
+		Mode          *string    `yaml:"mode"`
+		RevealOnAdmin *bool      `yaml:"revealOnAdmin"`
+		Rules         []maskRule `yaml:"rules"`
+	} `yaml:"masking"`
+
+	Actions struct {
+		AllowedTypes    []string `yaml:"allowedTypes"`
+		AllowedRollouts []string `yaml:"allowedRollouts"`
+		LabelSelector   *string  `yaml:"labelSelector"`
+		Confirm         *bool    `yaml:"confirm"`
+		Token           *string  `yaml:"token"`
+	} `yaml:"actions"`
+
+	LeaderElection struct {
+		Enabled   *bool   `yaml:"enabled"`
+		Namespace *string `yaml:"namespace"`
+		Lease     *string `yaml:"lease"`
+	} `yaml:"leaderElection"`
+
+	Peers struct {
+		Service *string `yaml:"service"`
+		DNS     *string `yaml:"dns"`
+		Port    *int    `yaml:"port"`
+	} `yaml:"peers"`
+
+	Chaos struct {
+		Enabled       *bool    `yaml:"enabled"`
+		Namespace     *string  `yaml:"namespace"`
+		LabelSelector *string  `yaml:"labelSelector"`
+		Interval      *string  `yaml:"interval"`
+		Rate          *float64 `yaml:"rate"`
+		Count         *int     `yaml:"count"`
+		Percent       *float64 `yaml:"percent"`
+		MinAvailable  *int     `yaml:"minAvailable"`
+		DryRun        *bool    `yaml:"dryRun"`
+	} `yaml:"chaos"`
+
+	Endpoints map[string]bool `yaml:"endpoints"`
+}
+
+// configValue describes single resolved value and where it came from
+type configValue struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+const (
+	// namespace used when it isn't set and service account isn't mounted
+	defaultNamespace = "kad"
+	// namespace of pod mounted with service account token
+	serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// endpoints which can be disabled in configuration file
+var optionalEndpoints = []string{"heavy", "memory", "disk", "data", "slow", "hostname", "kubernetes", "metrics", "malware", "terminate", "peers"}
+
+// enabled reports if optional endpoint is enabled
+func (c Config) enabled(endpoint string) bool {
+	e, ok := c.Endpoints[endpoint]
+
+	return !ok || e
+}
+
+// parseConfigFile parses configuration file content
+func parseConfigFile(content []byte) (fileConfig, error) {
+	fc := fileConfig{}
+
+	d := yaml.NewDecoder(bytes.NewReader(content))
+	d.KnownFields(true)
+	if err := d.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+		return fc, fmt.Errorf("Unable to parse config file: %s", err)
+	}
+
+	for e := range fc.Endpoints {
+		if !contains(optionalEndpoints, e) {
+			return fc, fmt.Errorf("Unknown endpoint %s in config file", e)
+		}
+	}
+
+	return fc, nil
+}
+
+// resolver merges defaults, config file, environment and flags
+type resolver struct {
+	flags  *pflag.FlagSet
+	path   string
+	values []configValue
+}
+
+// str resolves single value, empty env or flag name means it's not used and
+// empty values are ignored
+func (r *resolver) str(name, def string, file *string, env, flag string) string {
+	v, src := def, "default"
+
+	if file != nil {
+		v, src = *file, "file "+r.path
+	}
+	if env != "" {
+		if ev := os.Getenv(env); ev != "" {
+			v, src = ev, "env "+env
+		}
+	}
+	if flag != "" && r.flags != nil && r.flags.Changed(flag) {
+		if fv := r.flags.Lookup(flag).Value.String(); fv != "" {
+			v, src = fv, "flag --"+flag
+		}
+	}
+
+	r.values = append(r.values, configValue{Name: name, Value: v, Source: src})
+
+	return v
+}
+
+// list resolves comma separated list, see str
+func (r *resolver) list(name string, def, file []string, env, flag string) []string {
+	var fv *string
+	if file != nil {
+		v := strings.Join(file, ",")
+		fv = &v
+	}
+
+	l := []string{}
+	for _, i := range strings.Split(r.str(name, strings.Join(def, ","), fv, env, flag), ",") {
+		if i = strings.TrimSpace(i); i != "" {
+			l = append(l, i)
+		}
+	}
+
+	return l
+}
+
+// float resolves float value, see str
+func (r *resolver) float(name string, def float64, file *float64, env, flag string) (float64, error) {
+	var fv *string
+	if file != nil {
+		v := strconv.FormatFloat(*file, 'f', -1, 64)
+		fv = &v
+	}
+
+	v := r.str(name, strconv.FormatFloat(def, 'f', -1, 64), fv, env, flag)
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Failed reading %s (%s): %s", name, v, err)
+	}
+
+	return f, nil
+}
+
+// integer resolves int value, see str
+func (r *resolver) integer(name string, def int, file *int, env, flag string) (int, error) {
+	var fv *string
+	if file != nil {
+		v := strconv.Itoa(*file)
+		fv = &v
+	}
+
+	v := r.str(name, strconv.Itoa(def), fv, env, flag)
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("Failed reading %s (%s): %s", name, v, err)
+	}
+
+	return i, nil
+}
+
+// boolean resolves bool value, see str
+func (r *resolver) boolean(name string, def bool, file *bool, env, flag string) (bool, error) {
+	var fv *string
+	if file != nil {
+		v := strconv.FormatBool(*file)
+		fv = &v
+	}
+
+	v := r.str(name, strconv.FormatBool(def), fv, env, flag)
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("Failed reading %s (%s): %s", name, v, err)
+	}
+
+	return b, nil
+}
+
+// loadConfig reads configuration file at path and resolves effective config
+func loadConfig(path string, flags *pflag.FlagSet) (Config, []configValue, error) {
+	fc := fileConfig{}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return Config{}, nil, fmt.Errorf("Unable to read config file %s: %s", path, err)
+	}
+	if err == nil {
+		if fc, err = parseConfigFile(content); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	r := &resolver{flags: flags, path: path}
+	c := Config{}
+
+	c.Listen = r.str("listen", ":5000", fc.Listen, "LISTEN_PORT", "")
+	c.ListenAdmin = r.str("listenAdmin", ":5001", fc.ListenAdmin, "LISTEN_ADMIN_PORT", "")
+	c.Color = r.str("color", "#ffffff", fc.Color, "COLOR", "color")
+	// COLOR has always overridden --color, deployments set it to tell
+	// versions apart
+	if ev := os.Getenv("COLOR"); ev != "" {
+		v := &r.values[len(r.values)-1]
+		c.Color, v.Value, v.Source = ev, ev, "env COLOR"
+	}
+	if err := r.namespaces(fc, &c); err != nil {
+		return c, nil, err
+	}
+	c.RedisServer = r.str("redis", "", fc.Redis, "REDIS_SERVER", "")
+	c.JaegerAgentHost = r.str("tracing.jaegerAgentHost", "", fc.Tracing.JaegerAgentHost, "OTEL_EXPORTER_JAEGER_AGENT_HOST", "")
+	c.DataDir = r.str("dataDir", "/data", fc.DataDir, "DATADIR", "")
+
+	if c.FailureProbability, err = r.float("failureProbability", 0, fc.FailureProbability, "", "failure-probability"); err != nil {
+		return c, nil, err
+	}
+	if c.FailureProbability > 1 || c.FailureProbability < 0 {
+		return c, nil, fmt.Errorf("Failure probabilty must be between 0 and 1")
+	}
+
+	if c.ExitDelay, err = r.integer("exitDelay", 5, fc.ExitDelay, "", "exit-delay"); err != nil {
+		return c, nil, err
+	}
+	if c.ShutdownTimeout, err = r.integer("shutdownTimeout", 10, fc.ShutdownTimeout, "", "shutdown-timeout"); err != nil {
+		return c, nil, err
+	}
+
+	c.Latency.Distribution = r.str("latency.distribution", distUniform, fc.Latency.Distribution, "", "latency-distribution")
+	if c.Latency.Ms, err = r.float("latency.ms", 0, fc.Latency.Ms, "", "latency-ms"); err != nil {
+		return c, nil, err
+	}
+	if c.Latency.Jitter, err = r.float("latency.jitter", 0, fc.Latency.Jitter, "", "latency-jitter"); err != nil {
+		return c, nil, err
+	}
+	if c.Latency.TailProbability, err = r.float("latency.tailProbability", 0, fc.Latency.TailProbability, "", ""); err != nil {
+		return c, nil, err
+	}
+	if c.Latency.TailMs, err = r.float("latency.tailMs", 0, fc.Latency.TailMs, "", ""); err != nil {
+		return c, nil, err
+	}
+	if err := c.Latency.validate(); err != nil {
+		return c, nil, err
+	}
+
+	if c.Ready, err = r.boolean("ready", true, fc.Ready, "", ""); err != nil {
+		return c, nil, err
+	}
+
+	mode := r.str("masking.mode", maskRedact, fc.Masking.Mode, "MASK_MODE", "mask-mode")
+	reveal, err := r.boolean("masking.revealOnAdmin", false, fc.Masking.RevealOnAdmin, "", "")
+	if err != nil {
+		return c, nil, err
+	}
+	if c.Masking, err = newMasking(mode, reveal, fc.Masking.Rules); err != nil {
+		return c, nil, err
+	}
+	// rules can be configured only in config file
+	rulesSrc := "default"
+	if len(fc.Masking.Rules) > 0 {
+		rulesSrc = "file " + path
+	}
+	r.values = append(r.values, configValue{Name: "masking.rules", Value: fmt.Sprintf("%d built-in, %d custom", len(defaultMaskRules), len(fc.Masking.Rules)), Source: rulesSrc})
+
+	if c.Actions, err = r.actions(fc); err != nil {
+		return c, nil, err
+	}
+	if c.Chaos, err = r.chaos(fc, c.Namespace); err != nil {
+		return c, nil, err
+	}
+
+	if c.LeaderElection.Enabled, err = r.boolean("leaderElection.enabled", false, fc.LeaderElection.Enabled, "LEADER_ELECTION", "leader-elect"); err != nil {
+		return c, nil, err
+	}
+	c.LeaderElection.Namespace = r.str("leaderElection.namespace", c.Namespace, fc.LeaderElection.Namespace, "", "")
+	c.LeaderElection.Lease = r.str("leaderElection.lease", defaultLeaseName, fc.LeaderElection.Lease, "LEASE_NAME", "lease-name")
+
+	c.Peers.Service = r.str("peers.service", "", fc.Peers.Service, "PEERS_SERVICE", "peers-service")
+	c.Peers.DNS = r.str("peers.dns", "", fc.Peers.DNS, "PEERS_DNS", "peers-dns")
+	if c.Peers.Port, err = r.integer("peers.port", listenPort(c.ListenAdmin), fc.Peers.Port, "", ""); err != nil {
+		return c, nil, err
+	}
+
+	// endpoints can be configured only in config file
+	c.Endpoints = map[string]bool{}
+	for _, e := range optionalEndpoints {
+		v, src := true, "default"
+		if fv, ok := fc.Endpoints[e]; ok {
+			v, src = fv, "file "+path
+		}
+		c.Endpoints[e] = v
+		r.values = append(r.values, configValue{Name: "endpoints." + e, Value: strconv.FormatBool(v), Source: src})
+	}
+
+	return c, r.values, nil
+}
+
+// namespaces resolves namespace of kad and watched namespaces, namespace of
+// service account is used when namespace isn't set
+func (r *resolver) namespaces(fc fileConfig, c *Config) error {
+	var err error
+
+	c.Namespace = r.str("namespace", defaultNamespace, fc.Namespace, "NAMESPACE", "namespace")
+	if v := &r.values[len(r.values)-1]; v.Source == "default" {
+		if ns, err := ioutil.ReadFile(serviceAccountNamespace); err == nil && len(bytes.TrimSpace(ns)) > 0 {
+			c.Namespace = string(bytes.TrimSpace(ns))
+			v.Value, v.Source = c.Namespace, "file "+serviceAccountNamespace
+		}
+	}
+
+	sel := r.str("namespaceSelector", "", fc.NamespaceSelector, "NAMESPACE_SELECTOR", "namespace-selector")
+	if c.NamespaceSelector, err = labels.Parse(sel); err != nil {
+		return fmt.Errorf("Invalid namespaceSelector %s: %s", sel, err)
+	}
+
+	// only namespaces matching selector are watched when it's set
+	def := []string{c.Namespace}
+	if !c.NamespaceSelector.Empty() {
+		def = nil
+	}
+	c.Namespaces = r.list("namespaces", def, fc.Namespaces, "NAMESPACES", "namespaces")
+
+	return nil
+}
+
+// actions resolves guard of kubernetes actions
+func (r *resolver) actions(fc fileConfig) (actionsConfig, error) {
+	var err error
+
+	a := actionsConfig{}
+
+	// allowed types can be configured only in config file
+	src := "default"
+	a.AllowedTypes = defaultAllowedTypes
+	if fc.Actions.AllowedTypes != nil {
+		a.AllowedTypes, src = fc.Actions.AllowedTypes, "file "+r.path
+	}
+	for _, t := range a.AllowedTypes {
+		if k, ok := resourceKindByName(t); !ok || !k.deletable() {
+			return a, fmt.Errorf("Unknown resource type %s in actions.allowedTypes, deletable types are %v", t, deletableTypeNames())
+		}
+	}
+	r.values = append(r.values, configValue{Name: "actions.allowedTypes", Value: strings.Join(a.AllowedTypes, ","), Source: src})
+
+	src = "default"
+	a.AllowedRollouts = rolloutActions
+	if fc.Actions.AllowedRollouts != nil {
+		a.AllowedRollouts, src = fc.Actions.AllowedRollouts, "file "+r.path
+	}
+	for _, ra := range a.AllowedRollouts {
+		if !contains(rolloutActions, ra) {
+			return a, fmt.Errorf("Unknown rollout action %s in actions.allowedRollouts", ra)
+		}
+	}
+	r.values = append(r.values, configValue{Name: "actions.allowedRollouts", Value: strings.Join(a.AllowedRollouts, ","), Source: src})
+
+	sel := r.str("actions.labelSelector", "", fc.Actions.LabelSelector, "ACTIONS_LABEL_SELECTOR", "")
+	if a.Selector, err = labels.Parse(sel); err != nil {
+		return a, fmt.Errorf("Invalid actions.labelSelector %s: %s", sel, err)
+	}
+
+	if a.Confirm, err = r.boolean("actions.confirm", false, fc.Actions.Confirm, "", "confirm-actions"); err != nil {
+		return a, err
+	}
+
+	// token isn't accepted as flag to keep it out of process list
+	a.Token = r.str("actions.token", "", fc.Actions.Token, "ACTIONS_TOKEN", "")
+	if a.Token != "" {
+		r.values[len(r.values)-1].Value = redactedValue
+	}
+
+	return a, nil
+}
+
+// chaos resolves chaos loop, pods are killed in namespace of kad by default
+func (r *resolver) chaos(fc fileConfig, namespace string) (chaosConfig, error) {
+	var err error
+
+	c := chaosConfig{}
+
+	if c.Enabled, err = r.boolean("chaos.enabled", false, fc.Chaos.Enabled, "CHAOS_ENABLED", "chaos"); err != nil {
+		return c, err
+	}
+	c.Namespace = r.str("chaos.namespace", namespace, fc.Chaos.Namespace, "", "")
+	c.LabelSelector = r.str("chaos.labelSelector", "", fc.Chaos.LabelSelector, "CHAOS_LABEL_SELECTOR", "")
+	c.Interval = r.str("chaos.interval", "", fc.Chaos.Interval, "", "")
+	if c.Rate, err = r.float("chaos.rate", 0, fc.Chaos.Rate, "", ""); err != nil {
+		return c, err
+	}
+	if c.Count, err = r.integer("chaos.count", 0, fc.Chaos.Count, "", ""); err != nil {
+		return c, err
+	}
+	if c.Percent, err = r.float("chaos.percent", 0, fc.Chaos.Percent, "", ""); err != nil {
+		return c, err
+	}
+	if c.MinAvailable, err = r.integer("chaos.minAvailable", 1, fc.Chaos.MinAvailable, "", ""); err != nil {
+		return c, err
+	}
+	if c.DryRun, err = r.boolean("chaos.dryRun", false, fc.Chaos.DryRun, "", "chaos-dry-run"); err != nil {
+		return c, err
+	}
+
+	// label selector is required only to start the loop
+	if !c.Enabled {
+		return c, nil
+	}
+	if err := c.validate(); err != nil {
+		return c, fmt.Errorf("Invalid chaos config: %s", err)
+	}
+
+	return c, nil
+}
+
+// listenPort returns port of listen address, e.g. 5000 of :5000
+func listenPort(listen string) int {
+	_, p, err := net.SplitHostPort(listen)
+	if err != nil {
+		return 0
+	}
+	port, _ := strconv.Atoi(p)
+
+	return port
+}
+
+func contains(l []string, s string) bool {
+	for _, i := range l {
+		if i == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestResolverPrecedence(t *testing.T) {
+	file := "#0000ff"
+
+	tests := []struct {
+		name   string
+		file   *string
+		env    string
+		flag   string
+		value  string
+		source string
+	}{
+		{name: "default", value: "#ffffff", source: "default"},
+		{name: "file", file: &file, value: "#0000ff", source: "file kad.yml"},
+		{name: "env over file", file: &file, env: "#00ff00", value: "#00ff00", source: "env KAD_TEST_COLOR"},
+		{name: "flag over env", file: &file, env: "#00ff00", flag: "#ff0000", value: "#ff0000", source: "flag --color"},
+		{name: "flag over default", flag: "#ff0000", value: "#ff0000", source: "flag --color"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KAD_TEST_COLOR", tt.env)
+
+			flags := pflag.NewFlagSet("kad", pflag.ContinueOnError)
+			flags.String("color", "", "")
+			if tt.flag != "" {
+				if err := flags.Set("color", tt.flag); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r := &resolver{flags: flags, path: "kad.yml"}
+			if v := r.str("color", "#ffffff", tt.file, "KAD_TEST_COLOR", "color"); v != tt.value {
+				t.Errorf("Expected %s, got %s", tt.value, v)
+			}
+			if s := r.values[0].Source; s != tt.source {
+				t.Errorf("Expected source %s, got %s", tt.source, s)
+			}
+		})
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kad.yml")
+	if err := ioutil.WriteFile(path, []byte("color: \"#0000ff\"\nshutdownTimeout: 20\nexitDelay: 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("COLOR", "#00ff00")
+	t.Setenv("NAMESPACE", "")
+
+	flags := pflag.NewFlagSet("kad", pflag.ContinueOnError)
+	flags.String("color", "", "")
+	flags.String("namespace", "", "")
+	flags.Int("exit-delay", 5, "")
+	flags.Int("shutdown-timeout", 10, "")
+	for f, v := range map[string]string{"exit-delay": "1", "color": "#ff0000"} {
+		if err := flags.Set(f, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, values, err := loadConfig(path, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// COLOR overrides --color as it always did
+	if c.Color != "#00ff00" {
+		t.Errorf("Expected color from env, got %s", c.Color)
+	}
+	for _, v := range values {
+		if v.Name == "color" && v.Source != "env COLOR" {
+			t.Errorf("Expected color source env COLOR, got %s", v.Source)
+		}
+	}
+	if _, err := os.Stat(serviceAccountNamespace); err != nil && c.Namespace != "kad" {
+		t.Errorf("Expected namespace kad outside of cluster, got %s", c.Namespace)
+	}
+	if c.ShutdownTimeout != 20 {
+		t.Errorf("Expected shutdown timeout from file, got %d", c.ShutdownTimeout)
+	}
+	if c.ExitDelay != 1 {
+		t.Errorf("Expected exit delay from flag, got %d", c.ExitDelay)
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// pod marker files start with this prefix followed by hostname
+	markerPrefix = "marker-"
+
+	maxUploadSize     = 1 << 30
+	defaultVerifySize = 1 << 20
+)
+
+// dataFile describes file in data directory
+type dataFile struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Dir      bool      `json:"dir"`
+	// first line of pod marker file, i.e. first start of pod
+	Marker string `json:"marker,omitempty"`
+	// every start of pod recorded in marker file
+	Starts []string `json:"starts,omitempty"`
+}
+
+// LastStart returns last start recorded in marker file
+func (f dataFile) LastStart() string {
+	if len(f.Starts) == 0 {
+		return ""
+	}
+
+	return f.Starts[len(f.Starts)-1]
+}
+
+// SizeHuman returns file size in human readable form
+func (f dataFile) SizeHuman() string {
+	return formatBytes(float64(f.Size))
+}
+
+// verifyResult is result of write-then-checksum round trip
+type verifyResult struct {
+	File    string `json:"file"`
+	Size    int64  `json:"size"`
+	Written string `json:"written"`
+	Read    string `json:"read"`
+	Match   bool   `json:"match"`
+}
+
+// listDataFiles returns files in data directory sorted by name
+func listDataFiles(dataDir string) ([]dataFile, error) {
+	files, err := ioutil.ReadDir(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := []dataFile{}
+	for _, f := range files {
+		df := dataFile{
+			Name:     f.Name(),
+			Size:     f.Size(),
+			Modified: f.ModTime(),
+			Dir:      f.IsDir(),
+		}
+
+		if !f.IsDir() && strings.HasPrefix(f.Name(), markerPrefix) {
+			if content, err := ioutil.ReadFile(filepath.Join(dataDir, f.Name())); err == nil {
+				for _, l := range strings.Split(string(content), "\n") {
+					if l = strings.TrimSpace(l); l != "" {
+						df.Starts = append(df.Starts, l)
+					}
+				}
+				if len(df.Starts) > 0 {
+					df.Marker = df.Starts[0]
+				}
+			}
+		}
+
+		r = append(r, df)
+	}
+
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Name < r[j].Name
+	})
+
+	return r, nil
+}
+
+// writeMarker appends start of this pod to its marker file in data
+// directory, first line shows when data of stable hostname were first seen
+func writeMarker(dataDir, hostname string, started time.Time) error {
+	name := filepath.Join(dataDir, markerPrefix+hostname)
+
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(f, "hostname=%s started=%s\n", hostname, started.Format(time.RFC3339))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// dataPath returns path of file in data directory, name must not contain path
+func dataPath(dataDir, name string) (string, error) {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return "", fmt.Errorf("Invalid file name %s", name)
+	}
+
+	return filepath.Join(dataDir, name), nil
+}
+
+// dataListHandler lists files in data directory
+func dataListHandler(w http.ResponseWriter, r *http.Request) {
+	files, err := listDataFiles(state.config().DataDir)
+	if err != nil {
+		http.Error(w, "Unable to list data directory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(files); err != nil {
+		log.Printf("Unable to encode data files: %s", err)
+	}
+}
+
+// dataFileHandler downloads, uploads or deletes file in data directory
+//
+//	GET    /data/{name}  download file
+//	PUT    /data/{name}  upload file from request body
+//	POST   /data/{name}  upload file from multipart form field file
+//	DELETE /data/{name}  delete file
+func dataFileHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "data-file")
+	defer span.End()
+
+	p, err := dataPath(state.config().DataDir, mux.Vars(r)["name"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		f, err := os.Open(p)
+		if err != nil {
+			http.Error(w, "Unable to open file: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+
+		st, err := f.Stat()
+		if err != nil || st.IsDir() {
+			http.Error(w, "Not a file", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": st.Name()}))
+		http.ServeContent(w, r, st.Name(), st.ModTime(), f)
+		return
+
+	case http.MethodPut, http.MethodPost:
+		// limit applies to multipart form parsed from body too
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+		var body io.Reader = r.Body
+
+		// uploaded file can't be posted again from confirmation page, page
+		// upload is guarded by CSRF token and bearer token only
+		if status, err := checkAction(r, state.config().Actions); err != nil {
+			log.Printf("Action %s %s from %s refused: %s", r.Method, r.URL.Path, r.RemoteAddr, err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			mf, _, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, "Missing file in form: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer mf.Close()
+			body = mf
+		}
+
+		// write into temporary file renamed over target once complete, failed
+		// upload keeps existing file
+		f, err := ioutil.TempFile(filepath.Dir(p), "."+filepath.Base(p)+".upload-")
+		if err != nil {
+			span.RecordError(err)
+			http.Error(w, "Unable to create file: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(f.Name())
+
+		n, err := io.Copy(f, body)
+		if err == nil {
+			err = f.Chmod(0644)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), p)
+		}
+		if err != nil {
+			span.RecordError(err)
+			http.Error(w, "Unable to write file: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Uploaded %d bytes into %s", n, p)
+
+		if r.Method == http.MethodPost {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		fmt.Fprintf(w, "Written %d bytes into %s\n", n, p)
+
+	case http.MethodDelete:
+		if !guardAction(w, r, state.config().Actions, fmt.Sprintf("Delete file %s?", p)) {
+			return
+		}
+
+		if err := os.Remove(p); err != nil {
+			http.Error(w, "Unable to delete file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Printf("Deleted %s on request from %s", p, r.RemoteAddr)
+		fmt.Fprintf(w, "Deleted %s\n", p)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// dataVerifyHandler writes random data, reads it back and compares checksums
+//
+// Query parameter size (default 1Mi) sets amount of data written.
+func dataVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "data-verify")
+	defer span.End()
+
+	size := int64(defaultVerifySize)
+	if v := r.URL.Query().Get("size"); v != "" {
+		q, err := resource.ParseQuantity(v)
+		if err != nil || q.Value() <= 0 || q.Value() > maxUploadSize {
+			http.Error(w, "Size must be positive quantity up to 1Gi", http.StatusBadRequest)
+			return
+		}
+		size = q.Value()
+	}
+
+	res := verifyResult{
+		File: filepath.Join(state.config().DataDir, fmt.Sprintf("%sverify-%s", diskFilePrefix, state.hostname())),
+		Size: size,
+	}
+
+	// write
+	f, err := os.Create(res.File)
+	if err != nil {
+		span.RecordError(err)
+		http.Error(w, "Unable to create file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(res.File)
+
+	wh := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(f, wh), rand.Reader, size); err != nil {
+		f.Close()
+		span.RecordError(err)
+		http.Error(w, "Unable to write file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		span.RecordError(err)
+		http.Error(w, "Unable to sync file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	f.Close()
+	res.Written = hex.EncodeToString(wh.Sum(nil))
+
+	// read back
+	f, err = os.Open(res.File)
+	if err != nil {
+		span.RecordError(err)
+		http.Error(w, "Unable to open file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	rh := sha256.New()
+	if _, err := io.Copy(rh, f); err != nil {
+		span.RecordError(err)
+		http.Error(w, "Unable to read file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Read = hex.EncodeToString(rh.Sum(nil))
+	res.Match = res.Written == res.Read
+
+	log.Printf("Data verification of %s: match=%t", res.File, res.Match)
+
+	w.Header().Set("Content-Type", "application/json")
+	if !res.Match {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Printf("Unable to encode verify result: %s", err)
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// files created by disk stress start with this prefix
+	diskFilePrefix = "kad-"
+	// fill files survive restarts, only they are removed by cleanup
+	diskFillPrefix = diskFilePrefix + "fill-"
+
+	diskBlockSize     = 1 << 20
+	defaultIOFileSize = 64 << 20
+	maxIOFileSize     = 1 << 30
+	defaultIODuration = time.Minute
+	maxIODuration     = 30 * time.Minute
+
+	// wait before retrying failed write
+	diskRetryInterval = time.Second
+
+	// error labels of disk_errors_total metric
+	diskErrorNoSpace = "enospc"
+	diskErrorOther   = "other"
+)
+
+// diskStatus describes disk stress state
+type diskStatus struct {
+	Dir       string    `json:"dir"`
+	Filled    int64     `json:"filled"`
+	Written   int64     `json:"written"`
+	Read      int64     `json:"read"`
+	LastError string    `json:"lastError,omitempty"`
+	IORunning bool      `json:"ioRunning"`
+	IOStarted time.Time `json:"ioStarted"`
+	// throughput of running I/O job in bytes per second
+	WriteRate float64 `json:"writeRate"`
+	ReadRate  float64 `json:"readRate"`
+}
+
+// FilledHuman returns size of fill files in human readable form
+func (s diskStatus) FilledHuman() string {
+	return formatBytes(float64(s.Filled))
+}
+
+// WriteRateHuman returns write throughput in human readable form
+func (s diskStatus) WriteRateHuman() string {
+	return formatBytes(s.WriteRate)
+}
+
+// ReadRateHuman returns read throughput in human readable form
+func (s diskStatus) ReadRateHuman() string {
+	return formatBytes(s.ReadRate)
+}
+
+// formatBytes formats bytes with binary unit, e.g. 1.5 MiB
+func formatBytes(b float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+	i := 0
+	for b >= 1024 && i < len(units)-1 {
+		b /= 1024
+		i++
+	}
+
+	return fmt.Sprintf("%.1f %s", b, units[i])
+}
+
+// diskStress writes and reads files in data directory
+type diskStress struct {
+	sync.Mutex
+	written   int64
+	read      int64
+	lastError string
+
+	ioCancel  context.CancelFunc
+	ioDone    chan struct{}
+	ioStarted time.Time
+	ioWritten int64
+	ioRead    int64
+}
+
+var disk = &diskStress{}
+
+// recordError stores error and counts it
+func (d *diskStress) recordError(err error) {
+	label := diskErrorOther
+	if errors.Is(err, syscall.ENOSPC) {
+		label = diskErrorNoSpace
+	}
+	diskErrors.WithLabelValues(label).Inc()
+
+	d.Lock()
+	d.lastError = err.Error()
+	d.Unlock()
+}
+
+// addWritten counts written bytes, ioJob marks bytes written by I/O stress
+func (d *diskStress) addWritten(n int64, ioJob bool) {
+	diskWritten.Add(float64(n))
+
+	d.Lock()
+	defer d.Unlock()
+
+	d.written += n
+	if ioJob {
+		d.ioWritten += n
+	}
+}
+
+// addRead counts read bytes
+func (d *diskStress) addRead(n int64) {
+	diskRead.Add(float64(n))
+
+	d.Lock()
+	defer d.Unlock()
+
+	d.read += n
+	d.ioRead += n
+}
+
+// writeFile writes size bytes into file name and syncs it, writing stops
+// between blocks when ctx is done
+func (d *diskStress) writeFile(ctx context.Context, name string, size int64, ioJob bool) (int64, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, diskBlockSize)
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+
+	var total int64
+	for total < size {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		b := buf
+		if rem := size - total; rem < int64(len(b)) {
+			b = b[:rem]
+		}
+
+		n, err := f.Write(b)
+		total += int64(n)
+		d.addWritten(int64(n), ioJob)
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, f.Sync()
+}
+
+// fill writes new file of size bytes into dir until ctx is done, files of
+// previous runs are kept
+func (d *diskStress) fill(ctx context.Context, dir string, size int64) (int64, error) {
+	name := filepath.Join(dir, fmt.Sprintf("%s%s-%d", diskFillPrefix, state.hostname(), time.Now().UnixNano()))
+
+	n, err := d.writeFile(ctx, name, size, false)
+	filledBytes(dir)
+
+	if err != nil {
+		d.recordError(err)
+	}
+
+	return n, err
+}
+
+// startIO writes and reads file of size in loop for duration
+func (d *diskStress) startIO(dir string, size int64, duration time.Duration) error {
+	d.Lock()
+	if d.ioCancel != nil {
+		d.Unlock()
+		return fmt.Errorf("I/O stress is already running")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	done := make(chan struct{})
+	d.ioCancel = cancel
+	d.ioDone = done
+	d.ioStarted = time.Now()
+	d.ioWritten = 0
+	d.ioRead = 0
+	d.Unlock()
+
+	name := filepath.Join(dir, diskFilePrefix+"io")
+
+	go func() {
+		defer func() {
+			cancel()
+			os.Remove(name)
+
+			d.Lock()
+			d.ioCancel = nil
+			d.ioDone = nil
+			d.Unlock()
+			close(done)
+
+			log.Printf("Disk I/O stress finished")
+		}()
+
+		// wait before retry, false means ctx is done
+		retry := func(err error) bool {
+			d.recordError(err)
+
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(diskRetryInterval):
+				return true
+			}
+		}
+
+		buf := make([]byte, diskBlockSize)
+		for ctx.Err() == nil {
+			if _, err := d.writeFile(ctx, name, size, true); err != nil {
+				if ctx.Err() != nil || !retry(err) {
+					return
+				}
+				continue
+			}
+
+			f, err := os.Open(name)
+			if err != nil {
+				if !retry(err) {
+					return
+				}
+				continue
+			}
+			for ctx.Err() == nil {
+				n, err := f.Read(buf)
+				d.addRead(int64(n))
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					d.recordError(err)
+					break
+				}
+			}
+			f.Close()
+		}
+	}()
+
+	return nil
+}
+
+// stopIO cancels running I/O stress and waits until it's finished
+func (d *diskStress) stopIO() {
+	d.Lock()
+	cancel, done := d.ioCancel, d.ioDone
+	d.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// cleanup stops I/O stress and removes files created in dir
+func (d *diskStress) cleanup(dir string) error {
+	d.stopIO()
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if !f.IsDir() && strings.HasPrefix(f.Name(), diskFillPrefix) {
+			if err := os.Remove(filepath.Join(dir, f.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	d.Lock()
+	d.lastError = ""
+	d.Unlock()
+	diskFilled.Set(0)
+
+	return nil
+}
+
+// filledBytes returns size of fill files in dir, files written before
+// restart are included
+func filledBytes(dir string) int64 {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	var n int64
+	for _, f := range files {
+		if !f.IsDir() && strings.HasPrefix(f.Name(), diskFillPrefix) {
+			n += f.Size()
+		}
+	}
+	diskFilled.Set(float64(n))
+
+	return n
+}
+
+func (d *diskStress) status(dir string) diskStatus {
+	filled := filledBytes(dir)
+
+	d.Lock()
+	defer d.Unlock()
+
+	s := diskStatus{
+		Dir:       dir,
+		Filled:    filled,
+		Written:   d.written,
+		Read:      d.read,
+		LastError: d.lastError,
+		IORunning: d.ioCancel != nil,
+	}
+
+	if s.IORunning {
+		s.IOStarted = d.ioStarted
+		if el := time.Since(d.ioStarted).Seconds(); el > 0 {
+			s.WriteRate = float64(d.ioWritten) / el
+			s.ReadRate = float64(d.ioRead) / el
+		}
+	}
+
+	return s
+}
+
+// diskFillHandler writes file of requested size into data directory
+//
+// Query parameter size (e.g. 1Gi) sets file size.
+func diskFillHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "disk-fill")
+	defer span.End()
+
+	q, err := resource.ParseQuantity(r.URL.Query().Get("size"))
+	if err != nil || q.Value() <= 0 {
+		http.Error(w, "Size must be positive quantity, e.g. 100Mi", http.StatusBadRequest)
+		return
+	}
+
+	dir := state.config().DataDir
+	n, err := disk.fill(r.Context(), dir, q.Value())
+	if err != nil {
+		span.RecordError(err)
+		http.Error(w, fmt.Sprintf("Written %d bytes into %s, failed: %s", n, dir, err), http.StatusInsufficientStorage)
+		return
+	}
+
+	log.Printf("Written %d bytes into %s", n, dir)
+	fmt.Fprintf(w, "Written %s into %s\n", formatBytes(float64(n)), dir)
+}
+
+// diskIOHandler starts I/O stress in data directory
+//
+// Query parameters size (default 64Mi, max 1Gi) and duration (default 1m, max 30m)
+// control size of file written and read back in loop.
+func diskIOHandler(w http.ResponseWriter, r *http.Request) {
+	size := int64(defaultIOFileSize)
+	if v := r.URL.Query().Get("size"); v != "" {
+		q, err := resource.ParseQuantity(v)
+		if err != nil || q.Value() <= 0 || q.Value() > maxIOFileSize {
+			http.Error(w, "Size must be positive quantity up to 1Gi, e.g. 100Mi", http.StatusBadRequest)
+			return
+		}
+		size = q.Value()
+	}
+
+	d := defaultIODuration
+	if v := r.URL.Query().Get("duration"); v != "" {
+		pd, err := time.ParseDuration(v)
+		if err != nil || pd <= 0 || pd > maxIODuration {
+			http.Error(w, fmt.Sprintf("Duration must be between 0s and %s", maxIODuration), http.StatusBadRequest)
+			return
+		}
+		d = pd
+	}
+
+	dir := state.config().DataDir
+	if err := disk.startIO(dir, size, d); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	log.Printf("Disk I/O stress started in %s with %d bytes file for %s", dir, size, d)
+	fmt.Fprintf(w, "Started I/O stress in %s for %s\n", dir, d)
+}
+
+// diskAdminHandler reports disk stress or cleans up
+//
+//	GET    /disk     disk stress status
+//	DELETE /disk     stop I/O stress and remove created files
+//	DELETE /disk/io  stop I/O stress only
+func diskAdminHandler(w http.ResponseWriter, r *http.Request) {
+	dir := state.config().DataDir
+
+	if r.Method == http.MethodDelete {
+		if strings.HasSuffix(r.URL.Path, "/io") {
+			disk.stopIO()
+		} else if err := disk.cleanup(dir); err != nil {
+			http.Error(w, "Cleanup failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Disk stress cleaned up on request from %s", r.RemoteAddr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(disk.status(dir)); err != nil {
+		log.Printf("Unable to encode disk status: %s", err)
+	}
+}
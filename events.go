@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// page blocks pushed to live dashboard, each is rendered into element with
+// id live-<name>
+var liveBlocks = []string{"hits", "ready", "faults", "chaos", "kubernetes"}
+
+const (
+	// changes not announced by notify (e.g. redis counter increased by
+	// other replica) are picked up by polling
+	livePollInterval = 2 * time.Second
+
+	// comment sent to keep idle connections open through proxies
+	liveKeepalive = 30 * time.Second
+)
+
+// eventHub wakes up live dashboard streams when something shown changes
+type eventHub struct {
+	sync.Mutex
+	subs   map[chan struct{}]bool
+	closed bool
+}
+
+var events = &eventHub{subs: map[chan struct{}]bool{}}
+
+// subscribe returns channel signalled on change, it's closed on shutdown
+func (h *eventHub) subscribe() chan struct{} {
+	h.Lock()
+	defer h.Unlock()
+
+	ch := make(chan struct{}, 1)
+	if h.closed {
+		close(ch)
+		return ch
+	}
+	h.subs[ch] = true
+	liveStreams.Set(float64(len(h.subs)))
+
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan struct{}) {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.subs[ch] {
+		delete(h.subs, ch)
+		liveStreams.Set(float64(len(h.subs)))
+	}
+}
+
+// notify signals all streams, bursts of changes are coalesced
+func (h *eventHub) notify() {
+	h.Lock()
+	defer h.Unlock()
+
+	for ch := range h.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// close ends all streams so servers can shut down
+func (h *eventHub) close() {
+	h.Lock()
+	defer h.Unlock()
+
+	h.closed = true
+	for ch := range h.subs {
+		close(ch)
+		delete(h.subs, ch)
+	}
+	liveStreams.Set(0)
+}
+
+// liveContent builds content of live blocks without counting page hit
+func liveContent(r *http.Request) pageContent {
+	pc := state.snapshot()
+	pc.CSRFToken = requestCSRFToken(r)
+
+	hits, err := currentHits(pc.RedisHost)
+	if err != nil {
+		pc.RedisError = err.Error()
+	}
+	pc.Hits = hits
+
+	readStatus(r.Context(), &pc)
+
+	return pc
+}
+
+// renderBlocks renders live blocks of root page
+func renderBlocks(t *template.Template, pc pageContent) (map[string]string, error) {
+	r := map[string]string{}
+
+	for _, name := range liveBlocks {
+		b := bytes.Buffer{}
+		if err := t.ExecuteTemplate(&b, name, pc); err != nil {
+			return nil, err
+		}
+		r[name] = b.String()
+	}
+
+	return r, nil
+}
+
+// writeEvent writes server-sent event, every line of data is sent in its own
+// data field, carriage return would end the field too so it is a line break
+func writeEvent(w http.ResponseWriter, event, data string) error {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\r", "\n")
+
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "event: %s\n", event)
+	for _, l := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", l)
+	}
+	b.WriteString("\n")
+
+	_, err := w.Write([]byte(b.String()))
+
+	return err
+}
+
+// eventsHandler streams changed blocks of root page as server-sent events,
+// event name is block name and data is rendered HTML
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	t, err := template.New("tpl").Funcs(templateFuncs).Parse(rootPage)
+	if err != nil {
+		log.Printf("Unable to parse template: %s", err)
+		http.Error(w, "Unable to parse template", http.StatusInternalServerError)
+		return
+	}
+
+	ch := events.subscribe()
+	defer events.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", livePollInterval.Milliseconds())
+	flusher.Flush()
+
+	poll := time.NewTicker(livePollInterval)
+	defer poll.Stop()
+	keepalive := time.NewTicker(liveKeepalive)
+	defer keepalive.Stop()
+
+	// blocks last sent to client, all blocks are sent on connect as page
+	// may be stale after reconnect
+	sent := map[string]string{}
+	first := true
+
+	for {
+		blocks, err := renderBlocks(t, liveContent(r))
+		if err != nil {
+			log.Printf("Unable to execute template: %s", err)
+			return
+		}
+
+		for _, name := range liveBlocks {
+			if !first && blocks[name] == sent[name] {
+				continue
+			}
+			if err := writeEvent(w, name, blocks[name]); err != nil {
+				return
+			}
+			sent[name] = blocks[name]
+		}
+		first = false
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-poll.C:
+		case <-keepalive.C:
+			if _, err := fmt.Fprintf(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
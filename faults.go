@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// fault describes failure injected into requests matching route pattern
+type fault struct {
+	// path pattern as accepted by path.Match, e.g. /slow or /api/*
+	Route       string  `json:"route"`
+	Probability float64 `json:"probability"`
+	Status      int     `json:"status"`
+	Body        string  `json:"body"`
+	Latency     delay   `json:"latency"`
+}
+
+func (f *fault) validate() error {
+	if f.Route == "" {
+		return fmt.Errorf("Missing route")
+	}
+	if _, err := path.Match(f.Route, ""); err != nil {
+		return fmt.Errorf("Invalid route pattern %s: %s", f.Route, err)
+	}
+	if f.Probability > 1 || f.Probability < 0 {
+		return fmt.Errorf("Probability must be between 0 and 1")
+	}
+	if f.Status == 0 {
+		f.Status = http.StatusInternalServerError
+	}
+	if f.Status < 100 || f.Status > 599 {
+		return fmt.Errorf("Invalid status code %d", f.Status)
+	}
+	if err := f.Latency.validate(); err != nil {
+		return err
+	}
+	if f.Body == "" {
+		f.Body = fmt.Sprintf("Fault injected on %s", f.Route)
+	}
+
+	return nil
+}
+
+// faultTable holds faults configured on runtime
+type faultTable struct {
+	sync.RWMutex
+	faults map[string]fault
+}
+
+var faults = &faultTable{faults: map[string]fault{}}
+
+// list returns faults sorted by route
+func (t *faultTable) list() []fault {
+	t.RLock()
+	defer t.RUnlock()
+
+	l := make([]fault, 0, len(t.faults))
+	for _, f := range t.faults {
+		l = append(l, f)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Route < l[j].Route
+	})
+
+	return l
+}
+
+// match returns most specific fault matching request path, exact route is
+// preferred over patterns and pattern with longest literal prefix wins
+func (t *faultTable) match(p string) (fault, bool) {
+	t.RLock()
+	defer t.RUnlock()
+
+	if f, ok := t.faults[p]; ok {
+		return f, true
+	}
+
+	best, found := fault{}, false
+	for _, f := range t.faults {
+		if ok, _ := path.Match(f.Route, p); !ok {
+			continue
+		}
+		if !found || moreSpecific(f.Route, best.Route) {
+			best, found = f, true
+		}
+	}
+
+	return best, found
+}
+
+// moreSpecific reports if route pattern a is more specific than b
+func moreSpecific(a, b string) bool {
+	pa, pb := literalPrefix(a), literalPrefix(b)
+	if pa != pb {
+		return pa > pb
+	}
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+
+	return a < b
+}
+
+// literalPrefix returns length of route before first wildcard
+func literalPrefix(route string) int {
+	if i := strings.IndexAny(route, `*?[\`); i >= 0 {
+		return i
+	}
+
+	return len(route)
+}
+
+func (t *faultTable) set(f fault) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.faults[f.Route] = f
+	events.notify()
+}
+
+// reset removes fault for route or all faults when route is empty
+func (t *faultTable) reset(route string) {
+	t.Lock()
+	defer t.Unlock()
+
+	if route == "" {
+		t.faults = map[string]fault{}
+	} else {
+		delete(t.faults, route)
+	}
+	events.notify()
+}
+
+// injectFaults applies matching fault to requests
+func injectFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := faults.match(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !sleepContext(r.Context(), f.Latency.duration()) {
+			return
+		}
+
+		if f.Probability > 0 && rand.Float64() < f.Probability {
+			faultsInjected.WithLabelValues(f.Route).Inc()
+			http.Error(w, f.Body, f.Status)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// faultsHandler manages fault table
+//
+//	GET    /faults          list faults
+//	PUT    /faults          set fault for route, fault is sent as JSON body
+//	DELETE /faults?route=/  remove fault for route, all faults without route
+func faultsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPut:
+		f := fault{}
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			http.Error(w, "Unable to parse fault: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := f.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		faults.set(f)
+		log.Printf("Fault set for %s: %+v", f.Route, f)
+
+	case http.MethodDelete:
+		route := r.URL.Query().Get("route")
+		faults.reset(route)
+		log.Printf("Faults reset for %q", route)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(faults.list()); err != nil {
+		log.Printf("Unable to encode faults: %s", err)
+	}
+}
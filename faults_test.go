@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFaultMatch(t *testing.T) {
+	ft := &faultTable{faults: map[string]fault{}}
+	for _, r := range []string{"/*", "/slow", "/api/*", "/api/v1/*", "/api/v1/inf?"} {
+		ft.faults[r] = fault{Route: r}
+	}
+
+	tests := []struct {
+		path  string
+		route string
+	}{
+		{path: "/slow", route: "/slow"},
+		{path: "/heavy", route: "/*"},
+		{path: "/api/info", route: "/api/*"},
+		{path: "/api/v1/info", route: "/api/v1/inf?"},
+		{path: "/api/v1/data", route: "/api/v1/*"},
+		{path: "/api/v1/info/hits", route: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			f, ok := ft.match(tt.path)
+			if ok != (tt.route != "") || f.Route != tt.route {
+				t.Errorf("Expected route %q, got %q", tt.route, f.Route)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// cookie and form field with CSRF token, token is compared with cookie
+	// so it works across replicas without shared state
+	csrfCookie = "kad_csrf"
+	csrfField  = "csrf"
+
+	// form field with confirmation and bearer token entered on confirmation
+	// page
+	confirmField = "confirm"
+	tokenField   = "token"
+)
+
+// defaultAllowedTypes can be deleted when actions.allowedTypes isn't set,
+// other deletable kinds have to be allowed in config file
+var defaultAllowedTypes = []string{"deploy", "pod", "rs", "svc"}
+
+// actionsConfig guards destructive kubernetes actions
+type actionsConfig struct {
+	// resource types which can be deleted
+	AllowedTypes []string
+	// rollout actions which can be run on deployments
+	AllowedRollouts []string
+	// only resources matching selector can be deleted
+	Selector labels.Selector
+	// page actions must be confirmed
+	Confirm bool
+	// bearer token required for actions, empty disables authentication
+	Token string
+}
+
+// typeAllowed reports if resource type is allowed by config
+func (a actionsConfig) typeAllowed(rt string) error {
+	if !contains(a.AllowedTypes, rt) {
+		return fmt.Errorf("Resource type %s is not allowed, allowed types are %v", rt, a.AllowedTypes)
+	}
+
+	return nil
+}
+
+// rolloutAllowed reports if rollout action is allowed by config
+func (a actionsConfig) rolloutAllowed(action string) error {
+	if !contains(a.AllowedRollouts, action) {
+		return fmt.Errorf("Rollout action %s is not allowed, allowed actions are %v", action, a.AllowedRollouts)
+	}
+
+	return nil
+}
+
+// labelsAllowed reports if resource labels match selector
+func (a actionsConfig) labelsAllowed(l map[string]string) error {
+	if !a.Selector.Matches(labels.Set(l)) {
+		return fmt.Errorf("Resource doesn't match label selector %s", a.Selector)
+	}
+
+	return nil
+}
+
+// csrfToken returns CSRF token of browser, new token is set when it's missing
+func csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if t := requestCSRFToken(r); t != "" {
+		return t
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	t := hex.EncodeToString(b)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    t,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return t
+}
+
+// requestCSRFToken returns CSRF token from request cookie
+func requestCSRFToken(r *http.Request) string {
+	c, err := r.Cookie(csrfCookie)
+	if err != nil {
+		return ""
+	}
+
+	return c.Value
+}
+
+// bearerToken returns token from Authorization header or token field of
+// posted form, body of other methods isn't read
+func bearerToken(r *http.Request) (string, bool) {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer "), true
+	}
+	if r.Method != http.MethodPost {
+		return "", false
+	}
+
+	return r.PostFormValue(tokenField), false
+}
+
+func equalTokens(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// checkAction authorizes destructive action, returns HTTP status and error
+// when request isn't allowed
+//
+// Requests with valid bearer token in Authorization header, DELETE, PUT and
+// JSON requests (browsers don't send them cross-site without CORS) skip CSRF
+// check, POST from page must carry CSRF token matching cookie.
+func checkAction(r *http.Request, a actionsConfig) (int, error) {
+	token, header := bearerToken(r)
+
+	if a.Token != "" && !equalTokens(token, a.Token) {
+		return http.StatusUnauthorized, fmt.Errorf("Valid bearer token is required")
+	}
+
+	if r.Method == http.MethodDelete || r.Method == http.MethodPut || isJSON(r) || (header && a.Token != "") {
+		return 0, nil
+	}
+
+	if err := checkCSRF(r); err != nil {
+		return http.StatusForbidden, err
+	}
+
+	return 0, nil
+}
+
+// checkCSRF checks CSRF token of form matches cookie
+func checkCSRF(r *http.Request) error {
+	cookie := requestCSRFToken(r)
+	if cookie == "" || !equalTokens(cookie, r.PostFormValue(csrfField)) {
+		return fmt.Errorf("Invalid CSRF token, reload page and try again")
+	}
+
+	return nil
+}
+
+// needsConfirmation reports if page action must be confirmed first, token
+// is asked for on confirmation page
+func needsConfirmation(r *http.Request, a actionsConfig) bool {
+	if r.Method != http.MethodPost || isJSON(r) || r.PostFormValue(confirmField) == "yes" {
+		return false
+	}
+	if _, header := bearerToken(r); header {
+		return false
+	}
+
+	return a.Confirm || (a.Token != "" && r.PostFormValue(tokenField) == "")
+}
+
+// confirmPage asks for confirmation of action, form is posted back with
+// same fields
+var confirmPage = `
+<html>
+<meta charset="utf-8">
+<head>
+<title>Confirm action</title>
+<link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0-alpha1/dist/css/bootstrap.min.css" rel="stylesheet" integrity="sha384-GLhlTQ8iRABdZLl6O3oVMWSktQOp6b7In1Zl3/Jr59b6EGGoI1aFkw7cmDA6j6gD" crossorigin="anonymous">
+</head>
+<body>
+<div class="container" style="padding: 10px">
+<div class="alert alert-warning">
+<form method="post" action="{{ .Action }}">
+<p>{{ .Message }}</p>
+{{ range $k, $v := .Fields }}<input type="hidden" name="{{ $k }}" value="{{ $v }}">
+{{ end }}<input type="hidden" name="confirm" value="yes">
+{{ if .Token }}<p>Token: <input type="password" name="token"></p>{{ end }}
+<input type="submit" class="btn btn-danger" value="Confirm"> <a href="/" class="btn btn-secondary">Cancel</a>
+</form>
+</div>
+</div>
+</body>
+</html>
+`
+
+// confirmation is content of confirmPage
+type confirmation struct {
+	Action  string
+	Message string
+	Fields  map[string]string
+	// ask for bearer token
+	Token bool
+}
+
+// confirmAction renders confirmation page for posted action, all posted
+// fields are sent again after confirmation
+func confirmAction(w http.ResponseWriter, r *http.Request, a actionsConfig, message string) {
+	c := confirmation{
+		Action:  r.URL.Path,
+		Message: message,
+		Fields:  map[string]string{},
+		Token:   a.Token != "",
+	}
+	for k := range r.PostForm {
+		if k != confirmField && k != tokenField {
+			c.Fields[k] = r.PostForm.Get(k)
+		}
+	}
+
+	t, err := template.New("confirm").Parse(confirmPage)
+	if err != nil {
+		log.Printf("Unable to parse template: %s", err)
+		http.Error(w, "Unable to parse template", http.StatusInternalServerError)
+		return
+	}
+	if err := t.Execute(w, c); err != nil {
+		log.Printf("Unable to execute template: %s", err)
+	}
+}
+
+// guardAction checks page or API action, it writes response and returns
+// false when action can't proceed
+func guardAction(w http.ResponseWriter, r *http.Request, a actionsConfig, message string) bool {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	if needsConfirmation(r, a) {
+		if err := checkCSRF(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return false
+		}
+		confirmAction(w, r, a, message)
+		return false
+	}
+
+	if status, err := checkAction(r, a); err != nil {
+		log.Printf("Action %s %s from %s refused: %s", r.Method, r.URL.Path, r.RemoteAddr, err)
+		http.Error(w, err.Error(), status)
+		return false
+	}
+
+	return true
+}
@@ -3,34 +3,28 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"math/rand"
 	"net"
 	"net/http"
 	"os"
-	"runtime"
+	"sort"
 	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
-	"go.opentelemetry.io/otel/attribute"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func rootHandler(w http.ResponseWriter, r *http.Request) {
-	var err error
 	ctx, span := tracer.Start(r.Context(), "heavy")
 	defer span.End()
 
-	err = addHit()
-	if err != nil {
-		log.Printf("Redis error: %e", err)
-		pc.RedisError = err.Error()
-		span.RecordError(err)
-	} else {
-		pc.RedisError = ""
-		pc.RedisPath = redisPath()
+	pc := readPage(ctx, r, true)
+	if pc.RedisError != "" {
+		span.RecordError(errors.New(pc.RedisError))
 	}
 
 	// check failure probability
@@ -43,8 +37,45 @@ func rootHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// check ready file
-	pc.Ready = isReady()
+	w.Header().Add("Vary", "Accept")
+	if wantsJSON(r) {
+		writeJSON(w, newInfo(pc))
+		return
+	}
+
+	pc.CSRFToken = csrfToken(w, r)
+
+	// render template
+	t, err := template.New("tpl").Funcs(templateFuncs).Parse(rootPage)
+	if err != nil {
+		span.RecordError(err)
+		log.Printf("Unable to parse template: %s", err)
+	}
+	err = t.Execute(w, pc)
+	if err != nil {
+		span.RecordError(err)
+		log.Printf("Unable to execute template: %s", err)
+	}
+}
+
+// readPage builds page content for this request only, page hit is counted
+// when count is set
+func readPage(ctx context.Context, r *http.Request, count bool) pageContent {
+	var err error
+
+	pc := state.snapshot()
+
+	if count {
+		pc.Hits, err = addHit(pc.RedisHost)
+	} else {
+		pc.Hits, err = currentHits(pc.RedisHost)
+	}
+	if err != nil {
+		log.Printf("Redis error: %e", err)
+		pc.RedisError = err.Error()
+	} else {
+		pc.RedisPath = redisPath()
+	}
 
 	// store request
 	pc.Request = r
@@ -62,34 +93,68 @@ func rootHandler(w http.ResponseWriter, r *http.Request) {
 		ha := Header{Name: k, Value: va}
 		pc.Headers = append(pc.Headers, ha)
 	}
+	sort.Slice(pc.Headers, func(i, j int) bool {
+		return pc.Headers[i].Name < pc.Headers[j].Name
+	})
 
 	// update config file context
-	readConfig()
+	pc.ConfFile = readConfig(pc.ConfigFilePath)
 
-	// read resources from kubernetes
-	if err := readResources(ctx); err != nil {
-		pc.KubernetesError = err.Error()
+	// mask secrets, they can be revealed only on admin port
+	m := state.config().Masking
+	pc.Revealed = m.RevealOnAdmin && servedOnAdmin(r)
+	for _, v := range pc.Vars {
+		v.mask(m, pc.Revealed)
+	}
+	for i := range pc.Headers {
+		pc.Headers[i].mask(m, pc.Revealed)
+	}
+	if !pc.Revealed {
+		pc.ConfFile = m.maskConfigFile(pc.ConfFile)
 	}
 
-	pc.PersistentFiles = readPersistentFiles()
+	readStatus(ctx, &pc)
 
-	// render template
-	t, err := template.New("tpl").Parse(rootPage)
-	if err != nil {
-		span.RecordError(err)
-		log.Printf("Unable to parse template: %s", err)
-	}
-	err = t.Execute(w, pc)
+	// errors are ignored, data directory is optional
+	pc.DataDir = state.config().DataDir
+	pc.PersistentFiles, _ = listDataFiles(pc.DataDir)
+	pc.ActionsToken = state.config().Actions.Token != ""
+
+	pc.LoadJobs = cpuLoad.list()
+	pc.Memory = memHog.status()
+	pc.Disk = disk.status(state.config().DataDir)
+	pc.Clients = clientsHealth()
+
+	return pc
+}
+
+// readStatus fills parts of page updated by live dashboard
+func readStatus(ctx context.Context, pc *pageContent) {
+	var err error
+
+	// check ready file
+	pc.Ready = serving()
+
+	// read resources from kubernetes
+	pc.Resources, err = readResources(ctx)
 	if err != nil {
-		span.RecordError(err)
-		log.Printf("Unable to execute template: %s", err)
+		pc.KubernetesError = err.Error()
 	}
+	pc.KubernetesSynced, _ = k8sCache.status()
+	pc.KubernetesHost = state.kubernetesHost()
+	pc.Access = access.get()
+
+	pc.Faults = faults.list()
+	pc.Chaos = chaos.get()
+	pc.Leader = leader.get()
 }
 
 func readyHandler(w http.ResponseWriter, r *http.Request) {
 	if !isReady() {
 		http.Error(w, fmt.Sprintf("NOT ready, %s exists", readyFile), http.StatusNotFound)
-	} else if checkReady {
+	} else if !state.config().Ready {
+		http.Error(w, "NOT ready, disabled in config", http.StatusNotFound)
+	} else if !terminating.Load() {
 		fmt.Fprintf(w, "OK")
 	} else {
 		http.Error(w, "NOT ready", http.StatusNotFound)
@@ -102,60 +167,25 @@ func liveHandler(w http.ResponseWriter, r *http.Request) {
 
 func terminateHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Terminating on request from %s", r.RemoteAddr)
-	log.Printf("Reporting this instance as NOT ready")
-	checkReady = false
 	fmt.Fprintf(w, "OK")
 
-	go func() {
-		time.Sleep(exitDelay)
-
-		exit <- nil
-	}()
-}
-
-// make heavy computation
-func heavyHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, span := tracer.Start(r.Context(), "heavy")
-	defer span.End()
-
-	fmt.Fprintf(w, "Starting heavy load\n")
-
-	go func() {
-		_, span := tracer.Start(ctx, "heavy-goroutines")
-		defer span.End()
-
-		f, err := os.Open(os.DevNull)
-		if err != nil {
-			span.RecordError(err)
-			panic(err)
-		}
-		defer f.Close()
-
-		n := runtime.NumCPU()
-		runtime.GOMAXPROCS(n)
-		span.SetAttributes(
-			attribute.Int("goroutines", n),
-		)
-
-		for i := 0; i < n; i++ {
-			go func() {
-				for {
-					fmt.Fprintf(f, ".")
-				}
-			}()
-		}
-	}()
-
-	time.Sleep(3 * time.Second)
-	fmt.Fprintf(w, "Executed heavy load\n")
+	// shutdown is already in progress when channel is full
+	select {
+	case terminate <- r.RemoteAddr:
+	default:
+	}
 }
 
-// make slow response
+// make slow response, latency from query parameters is applied by latency
+// middleware instead of default sleep
 func slowHandler(w http.ResponseWriter, r *http.Request) {
 	_, span := tracer.Start(r.Context(), "sleep")
-	time.Sleep(3 * time.Second)
 	defer span.End()
 
+	if _, ok, _ := delayFromQuery(r.URL.Query()); !ok {
+		time.Sleep(3 * time.Second)
+	}
+
 	fmt.Fprintf(w, "Executed slow load\n")
 }
 
@@ -175,8 +205,18 @@ func hostnameHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		fmt.Fprintf(w, "Failed reading hostname: %s", err)
-	} else {
+		return
+	}
+
+	switch l := leader.get(); {
+	case !l.Enabled:
 		fmt.Fprint(w, hn)
+	case l.Leader:
+		fmt.Fprintf(w, "%s (leader)", hn)
+	case l.Holder == "":
+		fmt.Fprintf(w, "%s (follower, leader isn't known)", hn)
+	default:
+		fmt.Fprintf(w, "%s (follower, leader is %s)", hn, l.Holder)
 	}
 }
 
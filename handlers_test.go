@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// TestReadPageDuringReload reads page while config is reloaded, run it with
+// -race
+func TestReadPageDuringReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kad.yml")
+	write := func(i int) {
+		content := fmt.Sprintf("color: \"#%06x\"\nlatency:\n  ms: %d\nmasking:\n  mode: partial\n", i, i%10)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(0)
+
+	flags := pflag.NewFlagSet("kad", pflag.ContinueOnError)
+	cfg, settings, err := loadConfig(path, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state.Lock()
+	state.Config = cfg
+	state.Settings = settings
+	state.ConfigFilePath = path
+	state.Vars = map[string]*envVar{"API_TOKEN": {Name: "API_TOKEN", Value: "abcdefghijklmnop"}}
+	state.Unlock()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 20; j++ {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("Authorization", "Bearer abcdefghijklmnop")
+				pc := readPage(context.Background(), r, j%2 == 0)
+				if pc.Color == "" {
+					t.Error("Expected color in page content")
+				}
+			}
+		}()
+	}
+
+	for i := 1; i <= 20; i++ {
+		write(i)
+		if err := reloadConfig(path, flags); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	if c := state.config().Color; c != "#000014" {
+		t.Errorf("Expected color of last reload, got %s", c)
+	}
+}
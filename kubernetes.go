@@ -2,22 +2,37 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/gorilla/mux"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-var namespace = pc.Namespace
+const (
+	// wait between attempts to connect to kubernetes
+	informerRetry = 30 * time.Second
+	// informers resync period, 0 disables resync
+	informerResync = 0
+)
 
-func getClientset() (*kubernetes.Clientset, error) {
+// newClientset creates clientset from KUBECONFIG or in-cluster config
+func newClientset() (*kubernetes.Clientset, error) {
 	var (
 		err    error
 		config *rest.Config
@@ -37,7 +52,13 @@ func getClientset() (*kubernetes.Clientset, error) {
 		}
 	}
 
-	pc.KubernetesHost = config.Host
+	state.Lock()
+	state.KubernetesHost = config.Host
+	state.Unlock()
+
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return countingTransport{next: rt}
+	})
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -47,52 +68,417 @@ func getClientset() (*kubernetes.Clientset, error) {
 	return clientset, nil
 }
 
-// read kubernetes resources in current namespaces and save it to rootPage
-func readResources(ictx context.Context) error {
-	ctx, span := tracer.Start(ictx, "read-k8s-resources")
-	defer span.End()
+// kubernetesHost returns API server host used by last created clientset
+func (s *appState) kubernetesHost() string {
+	s.RLock()
+	defer s.RUnlock()
 
-	cs, err := getClientset()
-	if err != nil {
-		span.RecordError(err)
-		return err
+	return s.KubernetesHost
+}
+
+// informers of one watched namespace
+type namespaceCache struct {
+	factory informers.SharedInformerFactory
+	stop    chan struct{}
+	// every started informer, core and kinds added later
+	informers []cache.SharedIndexInformer
+	// informers by kind name
+	kinds map[string]cache.SharedIndexInformer
+}
+
+// synced reports if every started informer of namespace has synced
+func (n *namespaceCache) synced() bool {
+	for _, i := range n.informers {
+		if !i.HasSynced() {
+			return false
+		}
 	}
 
-	// list pods
-	pl, err := cs.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
-	if err != nil {
-		span.RecordError(err)
-		return err
+	return true
+}
+
+// informer cache state
+type kubeCache struct {
+	sync.RWMutex
+	// namespaces from config are watched even before informers are started
+	static     []string
+	namespaces map[string]*namespaceCache
+	err        error
+}
+
+var k8sCache = &kubeCache{namespaces: map[string]*namespaceCache{}}
+
+// status returns error of cache start and if caches of all namespaces are
+// synced
+func (c *kubeCache) status() (bool, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.err != nil || len(c.namespaces) == 0 {
+		return false, c.err
+	}
+	for _, n := range c.namespaces {
+		if !n.synced() {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// watched returns sorted watched namespaces
+func (c *kubeCache) watched() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	r := append([]string{}, c.static...)
+	for ns := range c.namespaces {
+		if !contains(r, ns) {
+			r = append(r, ns)
+		}
+	}
+	sort.Strings(r)
+
+	return r
+}
+
+// watching reports if namespace is watched
+func (c *kubeCache) watching(ns string) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	_, ok := c.namespaces[ns]
+
+	return ok || contains(c.static, ns)
+}
+
+// add starts informers for resources shown on page in namespace
+func (c *kubeCache) add(cs *kubernetes.Clientset, ns string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.namespaces[ns]; ok {
+		return
+	}
+
+	f := informers.NewSharedInformerFactoryWithOptions(cs, informerResync, informers.WithNamespace(ns))
+
+	n := &namespaceCache{factory: f, stop: make(chan struct{}), kinds: map[string]cache.SharedIndexInformer{}}
+
+	// informers must be requested before factory is started, other kinds
+	// are added when access to them is checked
+	for _, k := range resourceKinds {
+		if k.core {
+			i := k.informer(f)
+			notifyChanges(i)
+			n.kinds[k.name] = i
+			n.informers = append(n.informers, i)
+		}
 	}
-	pc.Resources.Pods = pl.Items
 
-	// list services
-	sl, err := cs.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	c.namespaces[ns] = n
+
+	f.Start(n.stop)
+
+	go func() {
+		start := time.Now()
+		for t, ok := range f.WaitForCacheSync(n.stop) {
+			if !ok {
+				log.Printf("Informer cache for %s in %s failed to sync", t, ns)
+				return
+			}
+		}
+
+		events.notify()
+
+		log.Printf("Informer cache of namespace %s synced in %s", ns, time.Since(start).Round(time.Millisecond))
+	}()
+
+	log.Printf("Watching namespace %s", ns)
+	events.notify()
+	access.refresh()
+}
+
+// notifyChanges pushes every change of informer to live dashboard
+func notifyChanges(i cache.SharedIndexInformer) {
+	_, err := i.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { events.notify() },
+		UpdateFunc: func(interface{}, interface{}) { events.notify() },
+		DeleteFunc: func(interface{}) { events.notify() },
+	})
 	if err != nil {
-		span.RecordError(err)
-		return err
+		log.Printf("Unable to add informer event handler: %s", err)
+	}
+}
+
+// enableKinds starts informers of kinds which role is allowed to list and
+// watch, kinds are never stopped as informer factory can't remove them
+func (c *kubeCache) enableKinds(a Access) {
+	c.Lock()
+	defer c.Unlock()
+
+	for ns, n := range c.namespaces {
+		started := false
+		for _, k := range resourceKinds {
+			if _, ok := n.kinds[k.name]; ok || k.core {
+				continue
+			}
+			l, w := a.Result(ns, "list", k.resource), a.Result(ns, "watch", k.resource)
+			if l == nil || w == nil || !l.Allowed || !w.Allowed {
+				continue
+			}
+
+			i := k.informer(n.factory)
+			notifyChanges(i)
+			n.kinds[k.name] = i
+			n.informers = append(n.informers, i)
+			started = true
+
+			// status changes once informer of empty namespace syncs too
+			go func(i cache.SharedIndexInformer, stop chan struct{}) {
+				if cache.WaitForCacheSync(stop, i.HasSynced) {
+					events.notify()
+				}
+			}(i, n.stop)
+
+			log.Printf("Watching %s in namespace %s", k.resource, ns)
+		}
+		if started {
+			n.factory.Start(n.stop)
+		}
+	}
+}
+
+// remove stops informers of namespace
+func (c *kubeCache) remove(ns string) {
+	c.Lock()
+	defer c.Unlock()
+
+	n, ok := c.namespaces[ns]
+	if !ok {
+		return
+	}
+	close(n.stop)
+	delete(c.namespaces, ns)
+
+	log.Printf("Stopped watching namespace %s", ns)
+	events.notify()
+}
+
+// startInformers starts shared informers for resources shown on page in
+// every watched namespace, clientset creation is retried until it succeeds
+// or ctx is done
+func startInformers(ctx context.Context, cfg Config) {
+	var (
+		cs  *kubernetes.Clientset
+		err error
+	)
+
+	k8sCache.Lock()
+	k8sCache.static = cfg.Namespaces
+	k8sCache.Unlock()
+
+	for {
+		cs, err = getClientset()
+		if err == nil {
+			break
+		}
+
+		k8sCache.Lock()
+		first := k8sCache.err == nil
+		k8sCache.err = err
+		k8sCache.Unlock()
+
+		if first {
+			log.Printf("Unable to connect to kubernetes, retrying every %s: %s", informerRetry, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(informerRetry):
+		}
+	}
+
+	k8sCache.Lock()
+	k8sCache.err = nil
+	k8sCache.Unlock()
+
+	for _, ns := range cfg.Namespaces {
+		k8sCache.add(cs, ns)
 	}
-	pc.Resources.Services = sl.Items
 
-	// list deployments
-	dl, err := cs.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	// namespaces which stop matching selector are no longer watched
+	if !cfg.NamespaceSelector.Empty() {
+		sel := cfg.NamespaceSelector.String()
+		f := informers.NewSharedInformerFactoryWithOptions(cs, informerResync, informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = sel
+		}))
+
+		_, err := f.Core().V1().Namespaces().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(o interface{}) {
+				if ns, ok := o.(*v1.Namespace); ok {
+					k8sCache.add(cs, ns.Name)
+				}
+			},
+			DeleteFunc: func(o interface{}) {
+				if d, ok := o.(cache.DeletedFinalStateUnknown); ok {
+					o = d.Obj
+				}
+				if ns, ok := o.(*v1.Namespace); ok && !contains(cfg.Namespaces, ns.Name) {
+					k8sCache.remove(ns.Name)
+				}
+			},
+		})
+		if err != nil {
+			log.Printf("Unable to add namespace event handler: %s", err)
+		}
+
+		log.Printf("Watching namespaces matching %s", sel)
+		f.Start(ctx.Done())
+	}
+
+	<-ctx.Done()
+
+	for _, ns := range k8sCache.watched() {
+		k8sCache.remove(ns)
+	}
+}
+
+// read kubernetes resources in watched namespaces from informer cache,
+// resources are sorted by namespace and name
+func readResources(ictx context.Context) (Resources, error) {
+	_, span := tracer.Start(ictx, "read-k8s-resources")
+	defer span.End()
+
+	res := Resources{Namespaces: []string{}}
+
+	k8sCache.RLock()
+	err := k8sCache.err
+	started := len(k8sCache.namespaces) > 0
+	k8sCache.RUnlock()
+
 	if err != nil {
 		span.RecordError(err)
-		return err
+		return res, err
+	}
+	if !started {
+		return res, fmt.Errorf("Informer cache is not started yet")
 	}
-	pc.Resources.Deployments = dl.Items
 
-	// list replicasets
-	rl, err := cs.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+	// lists of kinds watched in any namespace
+	lists := map[string]*resourceList{}
+	actions := state.config().Actions
+
+	for _, ns := range k8sCache.watched() {
+		k8sCache.RLock()
+		n, ok := k8sCache.namespaces[ns]
+		kinds := map[string]cache.SharedIndexInformer{}
+		if ok {
+			for k, i := range n.kinds {
+				kinds[k] = i
+			}
+		}
+		k8sCache.RUnlock()
+		if !ok {
+			continue
+		}
+
+		if err := readEvents(n.factory, ns, &res); err != nil {
+			span.RecordError(err)
+			return res, err
+		}
+		res.Namespaces = append(res.Namespaces, ns)
+
+		for _, k := range resourceKinds {
+			i, ok := kinds[k.name]
+			if !ok || k.details == nil {
+				continue
+			}
+			l, ok := lists[k.name]
+			if !ok {
+				l = &resourceList{Name: k.name, Kind: k.kind, Title: k.title, Resource: k.resource, Deletable: k.deletable() && actions.typeAllowed(k.name) == nil, Logs: k.logs}
+				lists[k.name] = l
+			}
+			if err := listKind(k, i, ns, l); err != nil {
+				span.RecordError(err)
+				return res, err
+			}
+		}
+	}
+
+	for _, k := range resourceKinds {
+		if l, ok := lists[k.name]; ok {
+			res.Kinds = append(res.Kinds, *l)
+		}
+	}
+
+	return res, nil
+}
+
+// readEvents appends most recent events in namespace to res
+func readEvents(f informers.SharedInformerFactory, ns string, res *Resources) error {
+	el, err := f.Core().V1().Events().Lister().Events(ns).List(labels.Everything())
 	if err != nil {
-		span.RecordError(err)
 		return err
 	}
-	pc.Resources.ReplicaSets = rl.Items
+	sort.Slice(el, func(i, j int) bool { return eventTime(*el[i]).After(eventTime(*el[j])) })
+	if len(el) > maxEvents {
+		el = el[:maxEvents]
+	}
+	for _, i := range el {
+		res.Events = append(res.Events, *i)
+	}
 
 	return nil
 }
 
+// countingTransport counts requests sent to kubernetes API
+type countingTransport struct {
+	next http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(r)
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	kubernetesAPIRequests.WithLabelValues(r.Method, code).Inc()
+
+	switch {
+	case err != nil:
+		kubeClient.observe(err)
+	case resp.StatusCode >= 500:
+		kubeClient.observe(fmt.Errorf("API server returned %s", resp.Status))
+	default:
+		kubeClient.observe(nil)
+	}
+
+	return resp, err
+}
+
+// requestNamespace returns namespace of action from namespace query parameter
+// or form field, namespace from config is used when it's not set
+func requestNamespace(r *http.Request) (string, error) {
+	ns := r.FormValue("namespace")
+	if ns == "" {
+		ns = state.config().Namespace
+	}
+
+	if !k8sCache.watching(ns) {
+		return ns, fmt.Errorf("Namespace %s is not watched", ns)
+	}
+
+	return ns, nil
+}
+
+// kubernetesDeleteHandler deletes resource, namespace is set by namespace
+// query parameter or form field
+//
+//	POST   /kubernetes/delete/{type}/{name}  delete from page, CSRF token is required
+//	DELETE /kubernetes/delete/{type}/{name}  delete from API
 func kubernetesDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, span := tracer.Start(r.Context(), "delete-k8s-resources")
 	defer span.End()
@@ -113,6 +499,28 @@ func kubernetesDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	dt, ok := resourceKindByName(rt)
+	if !ok || !dt.deletable() {
+		http.Error(w, "Unknown resource", http.StatusBadRequest)
+		return
+	}
+
+	a := state.config().Actions
+	if err := a.typeAllowed(rt); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !guardAction(w, r, a, fmt.Sprintf("Delete %s %s?", dt.kind, name)) {
+		return
+	}
+
+	ns, err := requestNamespace(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
 	cs, err := getClientset()
 	if err != nil {
 		http.Error(w, "Can't connect to kubernetes", http.StatusBadRequest)
@@ -121,9 +529,23 @@ func kubernetesDeleteHandler(w http.ResponseWriter, r *http.Request) {
 
 	span.SetAttributes(
 		attribute.String("resources.name", name),
+		attribute.String("resources.namespace", ns),
 		attribute.String("resource.type", rt),
 	)
 
+	// check labels only when selector is set to save API request
+	if !a.Selector.Empty() {
+		l, err := dt.labels(ctx, cs, ns, name)
+		if err != nil {
+			http.Error(w, "Failed reading "+dt.kind+" "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := a.labelsAllowed(l); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
 	dp := metav1.DeletePropagationBackground
 	one := int64(1)
 	do := metav1.DeleteOptions{
@@ -131,36 +553,17 @@ func kubernetesDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		PropagationPolicy:  &dp,
 	}
 
-	switch rt {
-	case "pod":
-		if err := cs.CoreV1().Pods(namespace).Delete(ctx, name, do); err != nil {
-			http.Error(w, "Failed deleting pod "+err.Error(), http.StatusBadRequest)
-			return
-		}
-	case "deploy":
-		if err := cs.AppsV1().Deployments(namespace).Delete(ctx, name, do); err != nil {
-			http.Error(w, "Failed deleting deployment "+err.Error(), http.StatusBadRequest)
-			return
-		}
-
-	case "rs":
-		if err := cs.AppsV1().ReplicaSets(namespace).Delete(ctx, name, do); err != nil {
-			http.Error(w, "Failed deleting replicaset "+err.Error(), http.StatusBadRequest)
-			return
-		}
-
-	case "svc":
-		if err := cs.CoreV1().Services(namespace).Delete(ctx, name, do); err != nil {
-			http.Error(w, "Failed deleting service "+err.Error(), http.StatusBadRequest)
-			return
-		}
-
-	default:
-		http.Error(w, "Unknown resource", http.StatusBadRequest)
+	if err := dt.delete(ctx, cs, ns, name, do); err != nil {
+		span.RecordError(err)
+		http.Error(w, "Failed deleting "+dt.kind+" "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Deleted %s/%s", rt, name)
+	log.Printf("Deleted %s %s/%s on request from %s", rt, ns, name, r.RemoteAddr)
 
-	http.Redirect(w, r, "http://"+r.Host, http.StatusSeeOther)
+	if r.Method == http.MethodDelete {
+		fmt.Fprintf(w, "Deleted %s %s/%s\n", dt.kind, ns, name)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
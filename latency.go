@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// latency distributions
+const (
+	distFixed    = "fixed"
+	distUniform  = "uniform"
+	distNormal   = "normal"
+	distLongTail = "longtail"
+)
+
+var distributions = []string{distFixed, distUniform, distNormal, distLongTail}
+
+// maxLatency limits every delay and its parts
+const maxLatency = time.Minute
+
+// probes and metrics aren't delayed, slow probes would restart pod
+var latencyExempt = []string{"/check/live", "/check/ready", "/metrics"}
+
+// delay describes latency added to request
+type delay struct {
+	// one of distributions, empty means uniform
+	Distribution string `json:"distribution,omitempty"`
+	// base latency in milliseconds
+	Ms float64 `json:"ms"`
+	// half-width of uniform distribution or standard deviation of normal
+	// distribution in milliseconds
+	Jitter float64 `json:"jitter"`
+	// probability of spike for longtail distribution, defaults to 0.01
+	TailProbability float64 `json:"tailProbability,omitempty"`
+	// spike added by longtail distribution, defaults to 10 times base
+	TailMs float64 `json:"tailMs,omitempty"`
+}
+
+func (d delay) validate() error {
+	if d.Distribution != "" && !contains(distributions, d.Distribution) {
+		return fmt.Errorf("Unknown latency distribution %s", d.Distribution)
+	}
+	max := float64(maxLatency / time.Millisecond)
+	for _, ms := range []float64{d.Ms, d.Jitter, d.TailMs} {
+		// negated check rejects NaN too
+		if !(ms >= 0 && ms <= max) {
+			return fmt.Errorf("Latency must be between 0 and %gms", max)
+		}
+	}
+	if !(d.TailProbability >= 0 && d.TailProbability <= 1) {
+		return fmt.Errorf("Tail probability must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// duration returns random duration from delay distribution, it's capped at
+// maxLatency
+func (d delay) duration() time.Duration {
+	ms := d.Ms
+
+	switch d.Distribution {
+	case distFixed:
+
+	case distNormal:
+		ms += rand.NormFloat64() * d.Jitter
+
+	case distLongTail:
+		ms += (rand.Float64()*2 - 1) * d.Jitter
+
+		tp, tms := d.TailProbability, d.TailMs
+		if tp == 0 {
+			tp = 0.01
+		}
+		if tms == 0 {
+			tms = 10 * d.Ms
+		}
+		if rand.Float64() < tp {
+			ms += tms
+		}
+
+	default:
+		ms += (rand.Float64()*2 - 1) * d.Jitter
+	}
+
+	if ms <= 0 {
+		return 0
+	}
+	if dd := time.Duration(ms * float64(time.Millisecond)); dd < maxLatency {
+		return dd
+	}
+
+	return maxLatency
+}
+
+// String formats delay for humans
+func (d delay) String() string {
+	dist := d.Distribution
+	if dist == "" {
+		dist = distUniform
+	}
+
+	s := fmt.Sprintf("%s %gms", dist, d.Ms)
+	if d.Jitter > 0 && dist != distFixed {
+		s += fmt.Sprintf(" ± %gms", d.Jitter)
+	}
+	if dist == distLongTail {
+		s += fmt.Sprintf(", tail %g/%gms", d.TailProbability, d.TailMs)
+	}
+
+	return s
+}
+
+// delayFromQuery reads delay from query parameters ms, jitter, dist, tail
+// and tailMs, second value reports if any parameter was present
+func delayFromQuery(q url.Values) (delay, bool, error) {
+	d := delay{}
+	found := false
+
+	for _, p := range []struct {
+		name string
+		val  *float64
+	}{
+		{"ms", &d.Ms},
+		{"jitter", &d.Jitter},
+		{"tail", &d.TailProbability},
+		{"tailMs", &d.TailMs},
+	} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return d, true, fmt.Errorf("Invalid %s parameter %s: %s", p.name, v, err)
+		}
+		*p.val = f
+		found = true
+	}
+
+	if dist := q.Get("dist"); dist != "" {
+		d.Distribution = dist
+		found = true
+	}
+
+	return d, found, d.validate()
+}
+
+// latency delays requests by latency from config, query parameters override
+// it only on /slow so they don't clash with parameters of other routes
+func latency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contains(latencyExempt, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		d := state.config().Latency
+		if r.URL.Path == "/slow" {
+			qd, ok, err := delayFromQuery(r.URL.Query())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if ok {
+				d = qd
+			}
+		}
+
+		if !sleepContext(r.Context(), d.duration()) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sleepContext waits for d, false is returned when ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	defaultLeaseName = "kad"
+
+	// followers take over lease not renewed for lease duration
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// leaderConfig enables election of leader, singleton work runs only on it
+type leaderConfig struct {
+	Enabled   bool
+	Namespace string
+	Lease     string
+}
+
+// leaderStatus is state of election shown on page
+type leaderStatus struct {
+	Enabled  bool   `json:"enabled"`
+	Lease    string `json:"lease,omitempty"`
+	Identity string `json:"identity,omitempty"`
+	Leader   bool   `json:"leader"`
+	// holder of lease, empty until it's observed
+	Holder string `json:"holder,omitempty"`
+	// time of last change of holder
+	Since time.Time `json:"since"`
+	// error of joining election, it's retried
+	Error string `json:"error,omitempty"`
+}
+
+// leaderElection holds state of election of this instance
+type leaderElection struct {
+	sync.RWMutex
+	status leaderStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var leader = &leaderElection{}
+
+func (l *leaderElection) get() leaderStatus {
+	l.RLock()
+	defer l.RUnlock()
+
+	return l.status
+}
+
+// isLeader reports if singleton work should run here, every instance is
+// leader when election is disabled
+func (l *leaderElection) isLeader() bool {
+	s := l.get()
+
+	return !s.Enabled || s.Leader
+}
+
+func (l *leaderElection) setHolder(holder string) {
+	l.Lock()
+	changed := l.status.Holder != holder
+	if changed {
+		l.status.Holder = holder
+		l.status.Since = time.Now()
+	}
+	l.Unlock()
+
+	if changed {
+		log.Printf("Leader of lease %s is %s", l.get().Lease, holder)
+		events.notify()
+	}
+}
+
+func (l *leaderElection) setError(err error) {
+	l.Lock()
+	l.status.Error = err.Error()
+	l.Unlock()
+
+	events.notify()
+}
+
+func (l *leaderElection) setLeader(leading bool) {
+	l.Lock()
+	l.status.Leader = leading
+	l.Unlock()
+
+	if leading {
+		leaderElected.Set(1)
+	} else {
+		leaderElected.Set(0)
+	}
+	events.notify()
+}
+
+// start joins election of lease until resign is called, lost leadership is
+// acquired again. Election fails closed, this replica is follower until it
+// joins, connection is retried with backoff.
+func (l *leaderElection) start(cfg leaderConfig) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	l.Lock()
+	l.status = leaderStatus{
+		Enabled: true,
+		Lease:   cfg.Namespace + "/" + cfg.Lease,
+	}
+	l.cancel = cancel
+	l.done = make(chan struct{})
+	l.Unlock()
+
+	go func() {
+		defer close(l.done)
+
+		b := backoff{}
+		for ctx.Err() == nil {
+			// elector is created for every round, it isn't meant to be
+			// run again after leadership is lost
+			le, err := l.elector(cfg)
+			if err == nil {
+				b.succeed()
+				// run returns when leadership is lost or election is cancelled
+				le.Run(ctx)
+				continue
+			}
+
+			b.fail(err)
+			l.setError(err)
+			log.Printf("Unable to join election of lease %s/%s, retrying in %s: %s", cfg.Namespace, cfg.Lease, time.Until(b.next).Round(time.Second), err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Until(b.next)):
+			}
+		}
+	}()
+}
+
+// elector creates elector of lease with hostname as identity
+func (l *leaderElection) elector(cfg leaderConfig) (*leaderelection.LeaderElector, error) {
+	cs, err := getClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Namespace: cfg.Namespace, Name: cfg.Lease},
+		Client:     cs.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: id},
+	}
+
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            cfg.Lease,
+		Callbacks: leaderelection.LeaderCallbacks{
+			// context of leading is cancelled when lease is lost or
+			// released, flag follows it
+			OnStartedLeading: func(ctx context.Context) {
+				log.Printf("Became leader of lease %s/%s", cfg.Namespace, cfg.Lease)
+				l.setLeader(true)
+				<-ctx.Done()
+				l.setLeader(false)
+				log.Printf("Stopped leading lease %s/%s", cfg.Namespace, cfg.Lease)
+			},
+			// required by elector, flag is cleared when leading ends
+			OnStoppedLeading: func() {},
+			OnNewLeader:      l.setHolder,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l.Lock()
+	l.status.Identity = id
+	l.status.Error = ""
+	l.Unlock()
+	events.notify()
+
+	log.Printf("Joining election of lease %s/%s as %s", cfg.Namespace, cfg.Lease, id)
+
+	return le, nil
+}
+
+// resign releases lease so other instance takes over without waiting for
+// lease to expire
+func (l *leaderElection) resign() {
+	l.RLock()
+	cancel, done := l.cancel, l.done
+	l.RUnlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(renewDeadline):
+		log.Printf("Unable to release lease in %s", renewDeadline)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// time window of busy-loop duty cycle
+	loadWindow = 100 * time.Millisecond
+
+	defaultLoadDuration = time.Minute
+	maxLoadDuration     = 30 * time.Minute
+)
+
+// loadJob is CPU load running on background
+type loadJob struct {
+	ID       int           `json:"id"`
+	Cores    int           `json:"cores"`
+	Percent  int           `json:"percent"`
+	Duration time.Duration `json:"duration"`
+	Started  time.Time     `json:"started"`
+
+	cancel context.CancelFunc
+}
+
+// Remaining returns time until job stops
+func (j loadJob) Remaining() time.Duration {
+	return time.Until(j.Started.Add(j.Duration)).Round(time.Second)
+}
+
+// loadJobs holds running load jobs
+type loadJobs struct {
+	sync.Mutex
+	jobs map[int]*loadJob
+	last int
+}
+
+var cpuLoad = &loadJobs{jobs: map[int]*loadJob{}}
+
+// start runs new load job, it's not bound to request context. Cores of all
+// jobs are limited to number of CPUs.
+func (l *loadJobs) start(cores, percent int, d time.Duration) (loadJob, error) {
+	l.Lock()
+	active := 0
+	for _, j := range l.jobs {
+		active += j.Cores
+	}
+	if active+cores > runtime.NumCPU() {
+		l.Unlock()
+		return loadJob{}, fmt.Errorf("Load jobs already use %d of %d cores, cancel them or ask for fewer cores", active, runtime.NumCPU())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	l.last++
+	j := &loadJob{
+		ID:       l.last,
+		Cores:    cores,
+		Percent:  percent,
+		Duration: d,
+		Started:  time.Now(),
+		cancel:   cancel,
+	}
+	l.jobs[j.ID] = j
+	loadJobsActive.Set(float64(len(l.jobs)))
+	l.Unlock()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < cores; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			busyLoop(ctx, percent)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+
+		l.Lock()
+		delete(l.jobs, j.ID)
+		loadJobsActive.Set(float64(len(l.jobs)))
+		l.Unlock()
+
+		log.Printf("Load job %d finished", j.ID)
+	}()
+
+	return *j, nil
+}
+
+// stop cancels job with id or all jobs when id is 0, returns number of
+// cancelled jobs
+func (l *loadJobs) stop(id int) int {
+	l.Lock()
+	defer l.Unlock()
+
+	n := 0
+	for _, j := range l.jobs {
+		if id == 0 || j.ID == id {
+			j.cancel()
+			n++
+		}
+	}
+
+	return n
+}
+
+// list returns running jobs sorted by id
+func (l *loadJobs) list() []loadJob {
+	l.Lock()
+	defer l.Unlock()
+
+	r := make([]loadJob, 0, len(l.jobs))
+	for _, j := range l.jobs {
+		r = append(r, *j)
+	}
+	sort.Slice(r, func(i, k int) bool {
+		return r[i].ID < r[k].ID
+	})
+
+	return r
+}
+
+// busyLoop keeps one core busy for percent of time until ctx is done
+func busyLoop(ctx context.Context, percent int) {
+	busy := loadWindow * time.Duration(percent) / 100
+
+	for {
+		start := time.Now()
+		for time.Since(start) < busy {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(loadWindow - busy):
+		}
+	}
+}
+
+// make heavy computation, job is started by POST
+//
+// Query parameters cores (default all), percent (default 100) and duration
+// (default 1m, max 30m) control the load.
+func heavyHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "heavy")
+	defer span.End()
+
+	q := r.URL.Query()
+
+	cores := runtime.NumCPU()
+	if v := q.Get("cores"); v != "" {
+		c, err := strconv.Atoi(v)
+		if err != nil || c < 1 || c > runtime.NumCPU() {
+			http.Error(w, fmt.Sprintf("Cores must be between 1 and %d", runtime.NumCPU()), http.StatusBadRequest)
+			return
+		}
+		cores = c
+	}
+
+	percent := 100
+	if v := q.Get("percent"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil || p < 1 || p > 100 {
+			http.Error(w, "Percent must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		percent = p
+	}
+
+	d := defaultLoadDuration
+	if v := q.Get("duration"); v != "" {
+		pd, err := time.ParseDuration(v)
+		if err != nil || pd <= 0 || pd > maxLoadDuration {
+			http.Error(w, fmt.Sprintf("Duration must be between 0s and %s", maxLoadDuration), http.StatusBadRequest)
+			return
+		}
+		d = pd
+	}
+
+	span.SetAttributes(
+		attribute.Int("goroutines", cores),
+		attribute.Int("percent", percent),
+		attribute.String("duration", d.String()),
+	)
+
+	j, err := cpuLoad.start(cores, percent, d)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	log.Printf("Load job %d started: %d cores at %d%% for %s", j.ID, cores, percent, d)
+
+	fmt.Fprintf(w, "Started heavy load job %d: %d cores at %d%% for %s\n", j.ID, cores, percent, d)
+}
+
+// heavyAdminHandler lists load jobs or cancels them
+//
+//	GET    /heavy        list running jobs
+//	DELETE /heavy?id=1   cancel job, all jobs without id
+func heavyAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		id := 0
+		if v := r.URL.Query().Get("id"); v != "" {
+			var err error
+			if id, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "Invalid job id "+v, http.StatusBadRequest)
+				return
+			}
+		}
+
+		n := cpuLoad.stop(id)
+		log.Printf("Cancelled %d load jobs on request from %s", n, r.RemoteAddr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(cpuLoad.list()); err != nil {
+		log.Printf("Unable to encode load jobs: %s", err)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultLogTail = 100
+	maxLogTail     = 5000
+
+	// longest log line sent to browser
+	maxLogLine = 64 << 10
+)
+
+// logView is content of logPage
+type logView struct {
+	Namespace  string
+	Pod        string
+	Containers []string
+	Container  string
+	Previous   bool
+	Tail       int64
+	StreamURL  string
+}
+
+// logOptions reads container, previous and tailLines query parameters
+func logOptions(q url.Values) (*v1.PodLogOptions, error) {
+	o := &v1.PodLogOptions{
+		Container: q.Get("container"),
+		Previous:  q.Get("previous") == "true",
+	}
+	// previous container is terminated, there is nothing to follow
+	o.Follow = !o.Previous
+
+	tail := int64(defaultLogTail)
+	if v := q.Get("tailLines"); v != "" {
+		t, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || t < 0 || t > maxLogTail {
+			return nil, strconv.ErrRange
+		}
+		tail = t
+	}
+	o.TailLines = &tail
+
+	return o, nil
+}
+
+// logsHandler shows log viewer of pod
+//
+//	GET /kubernetes/logs/{pod}?namespace=kad&container=app&previous=true&tailLines=100
+func logsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "logs")
+	defer span.End()
+
+	name := mux.Vars(r)["pod"]
+
+	ns, err := requestNamespace(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	o, err := logOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Tail lines must be between 0 and "+strconv.Itoa(maxLogTail), http.StatusBadRequest)
+		return
+	}
+
+	cs, err := getClientset()
+	if err != nil {
+		http.Error(w, "Can't connect to kubernetes", http.StatusBadRequest)
+		return
+	}
+
+	p, err := cs.CoreV1().Pods(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		http.Error(w, "Failed reading pod "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lv := logView{
+		Namespace: ns,
+		Pod:       name,
+		Container: o.Container,
+		Previous:  o.Previous,
+		Tail:      *o.TailLines,
+	}
+	for _, c := range p.Spec.Containers {
+		lv.Containers = append(lv.Containers, c.Name)
+	}
+	if lv.Container == "" && len(lv.Containers) > 0 {
+		lv.Container = lv.Containers[0]
+	}
+
+	q := url.Values{}
+	q.Set("namespace", ns)
+	q.Set("container", lv.Container)
+	q.Set("tailLines", strconv.FormatInt(lv.Tail, 10))
+	q.Set("previous", strconv.FormatBool(lv.Previous))
+	lv.StreamURL = "/kubernetes/logs/" + url.PathEscape(name) + "/stream?" + q.Encode()
+
+	t, err := template.New("logs").Parse(logPage)
+	if err != nil {
+		span.RecordError(err)
+		log.Printf("Unable to parse template: %s", err)
+		http.Error(w, "Unable to parse template", http.StatusInternalServerError)
+		return
+	}
+	if err := t.Execute(w, lv); err != nil {
+		span.RecordError(err)
+		log.Printf("Unable to execute template: %s", err)
+	}
+}
+
+// logsStreamHandler streams pod logs as server-sent events, every line is
+// sent as log event and end event is sent when log stream is closed
+//
+//	GET /kubernetes/logs/{pod}/stream?namespace=kad&container=app&previous=true&tailLines=100
+func logsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "logs-stream")
+	defer span.End()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	name := mux.Vars(r)["pod"]
+
+	ns, err := requestNamespace(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	span.SetAttributes(
+		attribute.String("resources.name", name),
+		attribute.String("resources.namespace", ns),
+	)
+
+	o, err := logOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Tail lines must be between 0 and "+strconv.Itoa(maxLogTail), http.StatusBadRequest)
+		return
+	}
+
+	cs, err := getClientset()
+	if err != nil {
+		http.Error(w, "Can't connect to kubernetes", http.StatusBadRequest)
+		return
+	}
+
+	// log stream is closed when client leaves or server shuts down
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	rs, err := cs.CoreV1().Pods(ns).GetLogs(name, o).Stream(ctx)
+	if err != nil {
+		span.RecordError(err)
+		http.Error(w, "Failed reading logs "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer rs.Close()
+
+	log.Printf("Streaming logs of %s/%s/%s to %s", ns, name, o.Container, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	flusher.Flush()
+
+	lines := make(chan string, 100)
+	readErr := make(chan error, 1)
+	go func() {
+		// error is sent before lines are closed, so it's always there once
+		// lines channel is closed
+		var err error
+		defer func() {
+			readErr <- err
+			close(lines)
+		}()
+
+		s := bufio.NewScanner(rs)
+		s.Buffer(make([]byte, 4096), maxLogLine)
+		for s.Scan() {
+			select {
+			case lines <- s.Text():
+			case <-ctx.Done():
+				err = ctx.Err()
+				return
+			}
+		}
+		err = s.Err()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopping.Done():
+			writeEvent(w, "end", "server is shutting down")
+			flusher.Flush()
+			return
+		case l, ok := <-lines:
+			if !ok {
+				msg := "log stream closed"
+				if err := <-readErr; err != nil {
+					msg += ": " + err.Error()
+				}
+				writeEvent(w, "end", msg)
+				flusher.Flush()
+				return
+			}
+			if err := writeEvent(w, "log", l); err != nil {
+				return
+			}
+			// flush once buffered lines are written
+			if len(lines) == 0 {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// logPage is log viewer, logs are appended as they are streamed
+var logPage = `
+<html>
+<meta charset="utf-8">
+<head>
+<title>Logs of {{ .Namespace }}/{{ .Pod }}</title>
+<link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0-alpha1/dist/css/bootstrap.min.css" rel="stylesheet" integrity="sha384-GLhlTQ8iRABdZLl6O3oVMWSktQOp6b7In1Zl3/Jr59b6EGGoI1aFkw7cmDA6j6gD" crossorigin="anonymous">
+<style>
+body {
+	padding: 10px;
+}
+pre {
+	background-color: #050505;
+	color: #e0e0e0;
+	padding: 10px;
+	border-radius: 2px;
+	font-size: 80%;
+	height: 80vh;
+	overflow-y: scroll;
+}
+</style>
+</head>
+<body>
+<div class="container">
+
+<p><a href="/">&larr; back</a> Logs of pod <code>{{ .Namespace }}/{{ .Pod }}</code> <span id="status" class="badge bg-secondary">connecting</span></p>
+
+<form method="get">
+<input type="hidden" name="namespace" value="{{ .Namespace }}">
+Container
+<select name="container">
+{{ range .Containers }}<option{{ if eq . $.Container }} selected{{ end }}>{{ . }}</option>
+{{ end }}</select>
+Tail <input type="number" name="tailLines" value="{{ .Tail }}" min="0" max="5000">
+<label><input type="checkbox" name="previous" value="true"{{ if .Previous }} checked{{ end }}> previous container</label>
+<input type="submit" value="Show">
+</form>
+
+<pre id="logs"></pre>
+
+</div>
+
+<script>
+var logs = document.getElementById("logs");
+var badge = document.getElementById("status");
+var stream = new EventSource({{ .StreamURL }});
+
+function append(line) {
+	// follow only when scrolled to bottom
+	var bottom = logs.scrollTop + logs.clientHeight >= logs.scrollHeight - 5;
+	logs.textContent += line + "\n";
+	if (bottom) {
+		logs.scrollTop = logs.scrollHeight;
+	}
+}
+
+stream.onopen = function() {
+	badge.textContent = "streaming";
+	badge.className = "badge bg-success";
+};
+stream.addEventListener("log", function(e) {
+	append(e.data);
+});
+stream.addEventListener("end", function(e) {
+	append("--- " + e.data + " ---");
+	badge.textContent = "closed";
+	badge.className = "badge bg-secondary";
+	stream.close();
+});
+stream.onerror = function() {
+	badge.textContent = "disconnected";
+	badge.className = "badge bg-danger";
+	stream.close();
+};
+</script>
+</body>
+</html>
+`
@@ -9,8 +9,11 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"go.opentelemetry.io/otel/sdk/resource"
@@ -24,16 +27,16 @@ import (
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/zap"
 
-	"github.com/go-redis/redis"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
-	apps_v1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 )
 
+// pageContent is the view model rendered by rootPage, it's built for every
+// request from the appState snapshot and never shared between requests
 type pageContent struct {
 	Vars           map[string]*envVar
 	Hostname       string
@@ -51,60 +54,180 @@ type pageContent struct {
 	Headers        []Header
 	Namespace      string
 
-	PageRefresh bool
+	Request          *http.Request
+	KubernetesError  string
+	KubernetesHost   string
+	KubernetesSynced bool
+	Access           Access
 
-	Request         *http.Request
-	KubernetesError string
-	KubernetesHost  string
-
-	PersistentFiles    []string
+	DataDir            string
+	PersistentFiles    []dataFile
 	FailureProbability float64
 
 	RemoteAddr string
+
+	// effective configuration
+	Settings          []configValue
+	ConfigReloadError string
+	ConfigReloaded    time.Time
+
+	Faults   []fault
+	Chaos    chaosStatus
+	Leader   leaderStatus
+	LoadJobs []loadJob
+	Memory   memoryStatus
+	Disk     diskStatus
+	Clients  []clientHealth
+
+	// secret values are shown unmasked
+	Revealed bool
+
+	// token sent with page actions
+	CSRFToken string
+	// page actions require bearer token
+	ActionsToken bool
+}
+
+// appState holds process-wide configuration shared by all requests
+type appState struct {
+	sync.RWMutex
+
+	Vars           map[string]*envVar
+	Hostname       string
+	Cmd            string
+	ConfigFilePath string
+	KubernetesHost string
+
+	Config      Config
+	Settings    []configValue
+	ReloadError string
+	Reloaded    time.Time
+}
+
+// snapshot returns page content prefilled with current state
+func (s *appState) snapshot() pageContent {
+	s.RLock()
+	defer s.RUnlock()
+
+	vars := make(map[string]*envVar, len(s.Vars))
+	for k, v := range s.Vars {
+		ev := *v
+		vars[k] = &ev
+	}
+
+	settings := make([]configValue, len(s.Settings))
+	copy(settings, s.Settings)
+
+	return pageContent{
+		Vars:               vars,
+		Hostname:           s.Hostname,
+		RedisHost:          s.Config.RedisServer,
+		Cmd:                s.Cmd,
+		ConfigFilePath:     s.ConfigFilePath,
+		Color:              s.Config.Color,
+		Namespace:          s.Config.Namespace,
+		KubernetesHost:     s.KubernetesHost,
+		FailureProbability: s.Config.FailureProbability,
+		Settings:           settings,
+		ConfigReloadError:  s.ReloadError,
+		ConfigReloaded:     s.Reloaded,
+	}
+}
+
+// hostname returns hostname of this pod
+func (s *appState) hostname() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.Hostname
+}
+
+// config returns current effective configuration
+func (s *appState) config() Config {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.Config
 }
 
 type Header struct {
-	Name  string
-	Value string
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	// name of mask rule which marked header as secret
+	Rule string `json:"rule,omitempty"`
 }
 
 type envVar struct {
-	Name      string
-	Value     string
-	Dangerous bool
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	Dangerous bool   `json:"dangerous"`
+	// name of mask rule which marked variable as secret
+	Rule string `json:"rule,omitempty"`
 }
 
 type Resources struct {
-	Pods        []v1.Pod
-	Services    []v1.Service
-	Deployments []apps_v1.Deployment
-	ReplicaSets []apps_v1.ReplicaSet
+	// namespaces with synced cache
+	Namespaces []string `json:"namespaces"`
+	// most recent events first
+	Events []v1.Event `json:"events"`
+	// lists of kinds in order of resourceKinds, kinds which aren't core
+	// are listed only when role allows to watch them
+	Kinds []resourceList `json:"kinds"`
 }
 
-func (e *envVar) detect() {
-	dv := strings.ToLower(e.Name + e.Value)
+// In returns resources in namespace
+func (r Resources) In(ns string) Resources {
+	n := Resources{Namespaces: []string{ns}}
+	for _, i := range r.Events {
+		if i.Namespace == ns {
+			n.Events = append(n.Events, i)
+		}
+	}
+	for _, l := range r.Kinds {
+		n.Kinds = append(n.Kinds, l.In(ns))
+	}
 
-	e.Dangerous = strings.Contains(dv, "pass") ||
-		strings.Contains(dv, "user") ||
-		strings.Contains(dv, "token") ||
-		strings.Contains(dv, "key")
+	return n
+}
+
+// mask marks variable as dangerous when it matches mask rule and masks its
+// value unless reveal is set
+func (e *envVar) mask(m masking, reveal bool) {
+	e.Rule, e.Dangerous = m.check(e.Name, e.Value)
+	if e.Dangerous && !reveal {
+		e.Value = m.mask(e.Value)
+	}
+}
+
+// mask masks header value when it matches mask rule unless reveal is set
+func (h *Header) mask(m masking, reveal bool) {
+	var ok bool
+
+	h.Rule, ok = m.check(h.Name, h.Value)
+	if ok && !reveal {
+		h.Value = m.mask(h.Value)
+	}
 }
 
 var (
 	configFile = "/etc/kad/config.yml"
-	pc         = pageContent{
+	state      = &appState{
 		Vars:           make(map[string]*envVar),
-		Hits:           0,
-		Cmd:            "",
 		ConfigFilePath: configFile,
 	}
 
-	checkReady = true
-	readyFile  = "/tmp/notready"
+	// hits counter used when redis is not configured
+	localHits int64
+
+	terminating atomic.Bool
+	readyFile   = "/tmp/notready"
+
+	// listener errors
+	exit = make(chan error, 2)
+	// remote address of terminate request
+	terminate = make(chan string, 1)
 
-	exit      = make(chan error)
-	exitDelay = 15 * time.Second
-	tracer    = otel.Tracer("go.6shore.net/kad")
+	tracer = otel.Tracer("go.6shore.net/kad")
 )
 
 func responseTime(next http.Handler) http.Handler {
@@ -143,48 +266,64 @@ func isReady() bool {
 	return err != nil
 }
 
+// serving reports if instance is ready and isn't terminating
+func serving() bool {
+	return isReady() && state.config().Ready && !terminating.Load()
+}
+
 func redisPath() string {
 	cluster := os.Getenv("CLUSTER")
 	return fmt.Sprintf("hits-%s", cluster)
 }
 
-func addHit() error {
+// addHit increments hits counter and returns its new value
+func addHit(redisHost string) (int, error) {
 	// TODO: add tracing
-	if pc.RedisHost == "" {
-		// Use pc variable
-		pc.Hits = pc.Hits + 1
-
-	} else {
-		// use redis
-		client := redis.NewClient(&redis.Options{
-			Addr:         pc.RedisHost,
-			DialTimeout:  300 * time.Millisecond,
-			ReadTimeout:  300 * time.Millisecond,
-			WriteTimeout: 300 * time.Millisecond,
-		})
+	var hits int
 
-		defer client.Close()
+	if redisHost == "" {
+		// use local counter
+		hits = int(atomic.AddInt64(&localHits, 1))
 
-		hits, err := client.Incr(redisPath()).Result()
+	} else {
+		// use shared redis client
+		rh, err := redisClient.incr(redisHost, redisPath())
 		if err != nil {
-			return fmt.Errorf("Unable to inc hits in redis: %s", err)
+			return 0, fmt.Errorf("Unable to inc hits in redis: %s", err)
 		}
-		pc.Hits = int(hits)
+		hits = int(rh)
 
 	}
 
-	pageHits.Observe(float64(pc.Hits))
+	pageHits.Observe(float64(hits))
+	events.notify()
 
-	return nil
+	return hits, nil
 }
 
-func readConfig() {
-	// read config file
-	if content, err := ioutil.ReadFile(configFile); err != nil {
-		log.Printf("Unable to read config file %s: %s", configFile, err)
-	} else {
-		pc.ConfFile = string(content)
+// currentHits returns hits without counting new one
+func currentHits(redisHost string) (int, error) {
+	if redisHost == "" {
+		return int(atomic.LoadInt64(&localHits)), nil
+	}
+
+	rh, err := redisClient.value(redisHost, redisPath())
+	if err != nil {
+		return 0, fmt.Errorf("Unable to read hits from redis: %s", err)
 	}
+
+	return int(rh), nil
+}
+
+// readConfig returns config file content
+func readConfig(path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Printf("Unable to read config file %s: %s", path, err)
+		return ""
+	}
+
+	return string(content)
 }
 
 func main() {
@@ -232,75 +371,74 @@ func main() {
 				}()
 			}
 
-			if fpr := cmd.Flag("failure-probability").Value.String(); fpr != "" {
-				fp, err := strconv.ParseFloat(fpr, 64)
-				if err != nil {
-					log.Fatalf("Failed reading request failure probability (%s): %s", fpr, err)
-				}
-				if fp > 1 || fp < 0 {
-					log.Fatal("Failure probabilty must be between 0 and 1")
-				}
+			// state is populated before servers are started, no locking needed
+			if cf := cmd.Flag("config").Value.String(); cf != "" {
+				configFile = cf
+				state.ConfigFilePath = cf
+			}
+
+			cfg, settings, err := loadConfig(configFile, cmd.Flags())
+			if err != nil {
+				log.Fatal(err)
+			}
+			state.Config = cfg
+			state.Settings = settings
 
-				pc.FailureProbability = fp
-				l.Info("Request failure probablity set", zap.Float64("probability", fp))
+			if err := watchConfig(ctx, configFile, cmd.Flags()); err != nil {
+				log.Printf("Unable to watch config file %s: %s", configFile, err)
+			}
+
+			if cfg.FailureProbability > 0 {
+				l.Info("Request failure probablity set", zap.Float64("probability", cfg.FailureProbability))
 			}
 
 			// read environment variables
 			for _, v := range os.Environ() {
-				pair := strings.Split(v, "=")
+				pair := strings.SplitN(v, "=", 2)
 
 				p := envVar{Name: pair[0], Value: pair[1]}
-				p.detect()
-				pc.Vars[pair[0]] = &p
-			}
-			listen := ":5000" //port 5000 is used by control center on macos
-			if lp := os.Getenv("LISTEN_PORT"); lp != "" {
-				listen = lp
-			}
-			listenAdmin := ":5001"
-			if lpa := os.Getenv("LISTEN_ADMIN_PORT"); lpa != "" {
-				listenAdmin = lpa
+				state.Vars[pair[0]] = &p
 			}
 
-			pc.Vars["listen"] = &envVar{Name: "listen", Value: listen}
-			pc.Vars["listenAdmin"] = &envVar{Name: "listenAdmin", Value: listenAdmin}
+			state.Vars["listen"] = &envVar{Name: "listen", Value: cfg.Listen}
+			state.Vars["listenAdmin"] = &envVar{Name: "listenAdmin", Value: cfg.ListenAdmin}
 
 			// read hostname
-			pc.Hostname, err = os.Hostname()
+			state.Hostname, err = os.Hostname()
 			if err != nil {
 				log.Printf("Unable to read hostname: %s", err)
 			}
 
 			// read command
-			pc.Cmd = strings.Join(os.Args, " ")
+			state.Cmd = strings.Join(os.Args, " ")
 
-			// setup color
-			pc.Color = cmd.Flag("color").Value.String()
-			if v := os.Getenv("COLOR"); v != "" {
-				pc.Color = v
-			}
-			if pc.Color == "" {
-				pc.Color = "#ffffff"
+			// mark data directory with this pod
+			if err := writeMarker(cfg.DataDir, state.Hostname, time.Now()); err != nil {
+				log.Printf("Unable to write marker into %s: %s", cfg.DataDir, err)
 			}
 
-			log.Printf("Using color: %s", pc.Color)
+			log.Printf("Using color: %s", cfg.Color)
 
-			if v := os.Getenv("NAMESPACE"); v != "" && pc.Namespace == "" {
-				pc.Namespace = v
-			}
-			if pc.Namespace == "" {
-				pc.Namespace = "kad"
+			// watch kubernetes resources and access to them
+			go startInformers(ctx, cfg)
+			go watchAccess(ctx)
+
+			// leader runs singleton work, e.g. chaos loop
+			if cfg.LeaderElection.Enabled {
+				leader.start(cfg.LeaderElection)
 			}
 
-			// detect redis
-			pc.RedisHost = os.Getenv("REDIS_SERVER")
+			// chaos loop is opt-in
+			if cfg.Chaos.Enabled {
+				chaos.configure(cfg.Chaos)
+			}
 
 			// gorilla mux
 			r := mux.NewRouter()
 
 			// tracing
-			if jh := os.Getenv("OTEL_EXPORTER_JAEGER_AGENT_HOST"); jh != "" {
-				tp, err := initTracer()
+			if jh := cfg.JaegerAgentHost; jh != "" {
+				tp, err := initTracer(jh)
 				if err != nil {
 					log.Fatal(err)
 				}
@@ -320,70 +458,149 @@ func main() {
 
 			// register handlers
 			r.HandleFunc("/", rootHandler)
-			r.HandleFunc("/heavy", heavyHandler)
-			r.HandleFunc("/slow", slowHandler)
-			r.HandleFunc("/hostname", hostnameHandler)
+			r.HandleFunc("/events", eventsHandler)
+			r.HandleFunc("/api/v1/info", infoHandler).Methods(http.MethodGet)
+			r.HandleFunc("/api/v1/info/{section}", infoHandler).Methods(http.MethodGet)
 			r.HandleFunc("/check/live", liveHandler)
 			r.HandleFunc("/check/ready", readyHandler)
-			r.HandleFunc("/kubernetes/delete/{type}/{name}", kubernetesDeleteHandler)
-			r.Handle("/metrics", promhttp.Handler())
+			if cfg.enabled("heavy") {
+				r.HandleFunc("/heavy", heavyHandler).Methods(http.MethodPost)
+			}
+			if cfg.enabled("memory") {
+				r.HandleFunc("/memory", memoryHandler)
+			}
+			if cfg.enabled("disk") {
+				r.HandleFunc("/disk/fill", diskFillHandler)
+				r.HandleFunc("/disk/io", diskIOHandler)
+			}
+			if cfg.enabled("data") {
+				r.HandleFunc("/data", dataListHandler).Methods(http.MethodGet)
+				r.HandleFunc("/data/verify", dataVerifyHandler).Methods(http.MethodPost)
+				r.HandleFunc("/data/{name}", dataFileHandler).Methods(http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete)
+			}
+			if cfg.enabled("slow") {
+				r.HandleFunc("/slow", slowHandler)
+			}
+			if cfg.enabled("hostname") {
+				r.HandleFunc("/hostname", hostnameHandler)
+			}
+			if cfg.enabled("kubernetes") {
+				r.HandleFunc("/kubernetes/delete/{type}/{name}", kubernetesDeleteHandler).Methods(http.MethodPost, http.MethodDelete)
+				r.HandleFunc("/kubernetes/deploy/{name}/{action}", rolloutHandler).Methods(http.MethodPost)
+				r.HandleFunc("/api/v1/deployments/{name}/{action}", rolloutHandler).Methods(http.MethodPost)
+				r.HandleFunc("/kubernetes/logs/{pod}", logsHandler).Methods(http.MethodGet)
+				r.HandleFunc("/kubernetes/logs/{pod}/stream", logsStreamHandler).Methods(http.MethodGet)
+			}
+			if cfg.enabled("metrics") {
+				r.Handle("/metrics", promhttp.Handler())
+			}
+			if cfg.enabled("peers") {
+				r.HandleFunc("/peers", peersHandler).Methods(http.MethodGet)
+			}
 
-			adminRouter.HandleFunc("/action/terminate", terminateHandler)
+			adminRouter.HandleFunc("/", rootHandler)
+			adminRouter.HandleFunc("/events", eventsHandler)
+			adminRouter.HandleFunc("/api/v1/info", infoHandler).Methods(http.MethodGet)
+			adminRouter.HandleFunc("/api/v1/info/{section}", infoHandler).Methods(http.MethodGet)
+			adminRouter.HandleFunc("/api/v1/instance", instanceHandler).Methods(http.MethodGet)
 			adminRouter.HandleFunc("/check/live", liveHandler)
 			adminRouter.HandleFunc("/check/ready", readyHandler)
-			adminRouter.HandleFunc("/check/ready", readyHandler)
 			adminRouter.Handle("/metrics", promhttp.Handler())
+			if cfg.enabled("heavy") {
+				adminRouter.HandleFunc("/heavy", heavyAdminHandler).Methods(http.MethodGet, http.MethodDelete)
+			}
+			if cfg.enabled("memory") {
+				adminRouter.HandleFunc("/memory", memoryAdminHandler).Methods(http.MethodGet, http.MethodDelete)
+			}
+			if cfg.enabled("disk") {
+				adminRouter.HandleFunc("/disk", diskAdminHandler).Methods(http.MethodGet, http.MethodDelete)
+				adminRouter.HandleFunc("/disk/io", diskAdminHandler).Methods(http.MethodDelete)
+			}
+			adminRouter.HandleFunc("/faults", faultsHandler).Methods(http.MethodGet, http.MethodPut, http.MethodDelete)
+			adminRouter.HandleFunc("/chaos", chaosHandler).Methods(http.MethodGet, http.MethodPut, http.MethodDelete)
+			if cfg.enabled("terminate") {
+				adminRouter.HandleFunc("/action/terminate", terminateHandler)
+			}
 
 			// malware simulaiton
-			adminRouter.HandleFunc("/malware", malwareHandler)
+			if cfg.enabled("malware") {
+				adminRouter.HandleFunc("/malware", malwareHandler)
+			}
 
 			// log requests
-			loggedRouter := handlers.LoggingHandler(os.Stdout, responseTime(r))
-			loggedAdminRouter := handlers.LoggingHandler(os.Stdout, adminRouter)
+			loggedRouter := handlers.LoggingHandler(os.Stdout, responseTime(latency(injectFaults(r))))
+			loggedAdminRouter := handlers.LoggingHandler(os.Stdout, onAdminPort(adminRouter))
+
+			srv := &http.Server{Addr: cfg.Listen, Handler: loggedRouter}
+			adminSrv := &http.Server{Addr: cfg.ListenAdmin, Handler: loggedAdminRouter}
+			servers := []*http.Server{srv, adminSrv}
+
+			// signals are caught before servers start, otherwise signal sent
+			// right after start kills instance without graceful shutdown
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
 
 			go func() {
-				l.Info("Listening on client port", zap.String("socket", listen))
-				if err := http.ListenAndServe(listen, loggedRouter); err != nil {
+				l.Info("Listening on client port", zap.String("socket", cfg.Listen))
+				if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 					log.Printf("Server failed with: %s", err)
 					exit <- err
 				}
 			}()
 
 			go func() {
-				l.Info("Listening on admin port", zap.String("socket", listenAdmin))
-				if err := http.ListenAndServe(listenAdmin, loggedAdminRouter); err != nil {
+				l.Info("Listening on admin port", zap.String("socket", cfg.ListenAdmin))
+				if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 					log.Printf("Admin server failed with: %s", err)
 					exit <- err
 				}
 			}()
 
-			err = <-exit
-			if err != nil {
+			cfg = state.config()
+			timeout := time.Duration(cfg.ShutdownTimeout) * time.Second
+
+			select {
+			case err := <-exit:
 				log.Printf("Terminating with error: %s", err)
-			}
+				shutdownServers(servers, timeout)
 
-			if delay := cmd.Flag("exit-delay").Value.String(); delay != "" {
-				d, err := strconv.Atoi(delay)
-				if err != nil {
-					log.Fatalf("Failed reading exit delay (%s): %s", delay, err)
-				}
-				log.Printf("Waiting %d seconds before exiting", d)
-				time.Sleep(time.Duration(d) * time.Second)
-			}
+			case sig := <-sigs:
+				log.Printf("Received %s, shutting down", sig)
+				gracefulShutdown(servers, time.Duration(state.config().ExitDelay)*time.Second, timeout)
 
+			case from := <-terminate:
+				log.Printf("Terminating on request from %s", from)
+				gracefulShutdown(servers, time.Duration(state.config().ExitDelay)*time.Second, timeout)
+			}
 		},
 	}
-	rootCmd.PersistentFlags().String("color", "", "Background color for main page")
+	rootCmd.PersistentFlags().String("config", configFile, "Path to configuration file")
+	rootCmd.PersistentFlags().String("color", "", "Background color for main page, COLOR environment variable takes precedence")
 	rootCmd.PersistentFlags().String("user", "", "Dummy flag")
 	rootCmd.PersistentFlags().Bool("fail", false, "Fail with non-zero exit code")
 	rootCmd.PersistentFlags().String("malware-url", "", "Malware URL to send secrets")
 	rootCmd.PersistentFlags().Float64("failure-probability", 0, "Failure probability for user requests (applies only on /, must be between 0 and 1)")
-	rootCmd.PersistentFlags().Int("exit-delay", 5, "Delay in seconds before exiting")
+	rootCmd.PersistentFlags().Int("exit-delay", 5, "Delay in seconds between reporting not ready and closing servers on shutdown (drain period)")
+	rootCmd.PersistentFlags().Int("shutdown-timeout", 10, "Time in seconds to wait for in-flight requests on shutdown")
+	rootCmd.PersistentFlags().String("latency-distribution", "", "Distribution of latency added to requests (fixed, uniform, normal, longtail)")
+	rootCmd.PersistentFlags().Float64("latency-ms", 0, "Latency in milliseconds added to requests")
+	rootCmd.PersistentFlags().Float64("latency-jitter", 0, "Latency jitter (uniform) or standard deviation (normal) in milliseconds")
+	rootCmd.PersistentFlags().Bool("confirm-actions", false, "Ask for confirmation before destructive Kubernetes actions")
+	rootCmd.PersistentFlags().String("namespace", "", "Namespace of actions, namespace of service account or kad is used by default")
+	rootCmd.PersistentFlags().String("namespaces", "", "Comma separated namespaces to watch")
+	rootCmd.PersistentFlags().String("namespace-selector", "", "Watch namespaces matching label selector")
+	rootCmd.PersistentFlags().Bool("leader-elect", false, "Elect leader of replicas with Kubernetes Lease, singleton work runs only on leader")
+	rootCmd.PersistentFlags().String("lease-name", "", "Name of Lease used for leader election")
+	rootCmd.PersistentFlags().String("peers-service", "", "Service whose endpoints are peers of this instance")
+	rootCmd.PersistentFlags().String("peers-dns", "", "Headless service name resolved to addresses of peers")
+	rootCmd.PersistentFlags().Bool("chaos", false, "Kill pods matching chaos.labelSelector periodically")
+	rootCmd.PersistentFlags().Bool("chaos-dry-run", false, "Only record pods chaos loop would kill")
+	rootCmd.PersistentFlags().String("mask-mode", "", "Masking of secret environment variables and headers (redact, partial, hash, off)")
 	rootCmd.Execute()
 }
 
-func initTracer() (*sdktrace.TracerProvider, error) {
-	exp, err := jaeger.New(jaeger.WithAgentEndpoint())
+func initTracer(agentHost string) (*sdktrace.TracerProvider, error) {
+	exp, err := jaeger.New(jaeger.WithAgentEndpoint(jaeger.WithAgentHost(agentHost)))
 	if err != nil {
 		return nil, err
 	}
@@ -410,26 +627,3 @@ func initTracer() (*sdktrace.TracerProvider, error) {
 
 	return tp, nil
 }
-
-func readPersistentFiles() []string {
-	dataDir := os.Getenv("DATADIR")
-	if dataDir == "" {
-		dataDir = "/data"
-	}
-
-	r := []string{}
-	files, err := ioutil.ReadDir(dataDir)
-	if err != nil {
-		return r
-	}
-
-	for _, f := range files {
-		if f.IsDir() {
-			r = append(r, f.Name()+" (d)")
-		} else {
-			r = append(r, f.Name())
-		}
-	}
-
-	return r
-}
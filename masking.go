@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// masking modes
+const (
+	maskOff     = "off"
+	maskRedact  = "redact"
+	maskPartial = "partial"
+	maskHash    = "hash"
+)
+
+const (
+	redactedValue = "********"
+
+	// shorter values are redacted in partial mode
+	partialMinLength = 12
+)
+
+var maskModes = []string{maskOff, maskRedact, maskPartial, maskHash}
+
+// maskHashKey is random key of hashes, it's generated on start so hashes
+// can be compared only within one instance and secrets can't be guessed by
+// hashing candidates
+var maskHashKey = newMaskHashKey()
+
+func newMaskHashKey() []byte {
+	k := make([]byte, 32)
+	if _, err := rand.Read(k); err != nil {
+		log.Fatalf("Unable to generate key of masking hashes: %s", err)
+	}
+
+	return k
+}
+
+// maskRule marks environment variable or header as secret, rule matches when
+// all its patterns match
+type maskRule struct {
+	Name         string `yaml:"name" json:"name"`
+	NamePattern  string `yaml:"namePattern" json:"namePattern,omitempty"`
+	ValuePattern string `yaml:"valuePattern" json:"valuePattern,omitempty"`
+
+	name  *regexp.Regexp
+	value *regexp.Regexp
+}
+
+// built-in rules, rules from config file are added to them
+var defaultMaskRules = []maskRule{
+	{Name: "password", NamePattern: `(?i)pass(word|wd)?`},
+	{Name: "token", NamePattern: `(?i)token|secret|credential`},
+	{Name: "key", NamePattern: `(?i)(^|[_-])(api[_-]?)?key($|[_-])`},
+	{Name: "user", NamePattern: `(?i)(^|_)user(name)?($|_)`},
+	{Name: "authorization header", NamePattern: `(?i)^(proxy-)?authorization$`},
+	{Name: "cookie header", NamePattern: `(?i)^(set-)?cookie$`},
+	{Name: "jwt", ValuePattern: `eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`},
+	{Name: "aws access key", ValuePattern: `\b(AKIA|ASIA)[A-Z0-9]{16}\b`},
+	{Name: "pem", ValuePattern: `-----BEGIN [A-Z ]*PRIVATE KEY-----`},
+	{Name: "url credentials", ValuePattern: `://[^/\s:@]+:[^/\s@]+@`},
+}
+
+// compile compiles rule patterns
+func (mr *maskRule) compile() error {
+	var err error
+
+	if mr.NamePattern == "" && mr.ValuePattern == "" {
+		return fmt.Errorf("Mask rule %s has no pattern", mr.Name)
+	}
+
+	if mr.NamePattern != "" {
+		if mr.name, err = regexp.Compile(mr.NamePattern); err != nil {
+			return fmt.Errorf("Invalid name pattern of mask rule %s: %s", mr.Name, err)
+		}
+	}
+	if mr.ValuePattern != "" {
+		if mr.value, err = regexp.Compile(mr.ValuePattern); err != nil {
+			return fmt.Errorf("Invalid value pattern of mask rule %s: %s", mr.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (mr maskRule) matches(name, value string) bool {
+	if mr.name != nil && !mr.name.MatchString(name) {
+		return false
+	}
+	if mr.value != nil && !mr.value.MatchString(value) {
+		return false
+	}
+
+	return true
+}
+
+// masking configures how secret values are shown
+type masking struct {
+	Mode string
+	// values are shown unmasked on admin port
+	RevealOnAdmin bool
+	Rules         []maskRule
+}
+
+// newMasking returns masking with default rules followed by rules, all rules
+// are compiled
+func newMasking(mode string, revealOnAdmin bool, rules []maskRule) (masking, error) {
+	if !contains(maskModes, mode) {
+		return masking{}, fmt.Errorf("Unknown masking mode %s, use one of %v", mode, maskModes)
+	}
+
+	m := masking{Mode: mode, RevealOnAdmin: revealOnAdmin}
+	for _, mr := range append(append([]maskRule{}, defaultMaskRules...), rules...) {
+		if err := mr.compile(); err != nil {
+			return masking{}, err
+		}
+		m.Rules = append(m.Rules, mr)
+	}
+
+	return m, nil
+}
+
+// check returns name of first rule matching name and value
+func (m masking) check(name, value string) (string, bool) {
+	for _, mr := range m.Rules {
+		if mr.matches(name, value) {
+			return mr.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// mask returns value masked according to mode
+func (m masking) mask(value string) string {
+	switch m.Mode {
+	case maskOff:
+		return value
+	case maskPartial:
+		rs := []rune(value)
+		if len(rs) < partialMinLength {
+			return redactedValue
+		}
+		return string(rs[:3]) + redactedValue + string(rs[len(rs)-2:])
+	case maskHash:
+		h := hmac.New(sha256.New, maskHashKey)
+		h.Write([]byte(value))
+		return "hmac:" + hex.EncodeToString(h.Sum(nil))[:12]
+	default:
+		return redactedValue
+	}
+}
+
+// key and value of YAML mapping or value of list item on one line, comments
+// are checked too as they often hold old values
+var configLinePattern = regexp.MustCompile(`^(\s*(?:#\s*)?(?:-\s+)?)(?:["']?([^\s#"':][^"':]*)["']?:(?:\s+|$))?(.*?)\s*$`)
+
+// indentation returns length of leading whitespace of line
+func indentation(l string) int {
+	return len(l) - len(strings.TrimLeft(l, " \t"))
+}
+
+// maskConfigFile masks values in configuration file content with the same
+// rules as environment variables, key of value is matched as name. Lines of
+// block scalar are masked when the key or whole block matches.
+func (m masking) maskConfigFile(content string) string {
+	lines := strings.Split(content, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		p := configLinePattern.FindStringSubmatch(l)
+		if p == nil {
+			continue
+		}
+		prefix, key, value := p[1], p[2], p[3]
+		if key == "" && prefix == "" || value == "" {
+			continue
+		}
+
+		// block scalar continues on lines indented more than its key
+		if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			end := i + 1
+			for end < len(lines) && (strings.TrimSpace(lines[end]) == "" || indentation(lines[end]) > indentation(l)) {
+				end++
+			}
+			if _, ok := m.check(key, strings.Join(lines[i+1:end], "\n")); ok {
+				for j := i + 1; j < end; j++ {
+					if v := strings.TrimSpace(lines[j]); v != "" {
+						lines[j] = lines[j][:indentation(lines[j])] + m.mask(v)
+					}
+				}
+			}
+			i = end - 1
+			continue
+		}
+
+		v := strings.Trim(value, `"'`)
+		if _, ok := m.check(key, v); ok {
+			lines[i] = strings.TrimSuffix(strings.TrimRight(l, " \t"), value) + m.mask(v)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// adminPortKey marks request served on admin port
+type adminPortKey struct{}
+
+// onAdminPort marks requests as served on admin port
+func onAdminPort(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminPortKey{}, true)))
+	})
+}
+
+// servedOnAdmin reports if request came to admin port
+func servedOnAdmin(r *http.Request) bool {
+	v, _ := r.Context().Value(adminPortKey{}).(bool)
+
+	return v
+}
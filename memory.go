@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// largest single allocation when container has no memory limit
+const maxMemorySize = 16 << 30
+
+// cgroup files with memory limit, v2 first
+var cgroupMemoryFiles = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+// cgroupMemoryLimit returns memory limit of container, 0 means no limit
+func cgroupMemoryLimit() (int64, error) {
+	for _, f := range cgroupMemoryFiles {
+		content, err := ioutil.ReadFile(f)
+		if err != nil {
+			continue
+		}
+
+		v := strings.TrimSpace(string(content))
+		if v == "max" {
+			return 0, nil
+		}
+
+		l, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("Unable to parse memory limit from %s: %s", f, err)
+		}
+
+		// cgroup v1 reports huge number when unlimited
+		if l >= 1<<62 {
+			return 0, nil
+		}
+
+		return l, nil
+	}
+
+	return 0, fmt.Errorf("Unable to find cgroup memory limit")
+}
+
+// memoryStatus describes memory held by memory hog
+type memoryStatus struct {
+	Held  int64 `json:"held"`
+	Limit int64 `json:"limit"`
+	// growth in bytes per second, 0 when not leaking
+	Rate int64 `json:"rate"`
+}
+
+// HeldHuman returns held memory in human readable form
+func (s memoryStatus) HeldHuman() string {
+	return resource.NewQuantity(s.Held, resource.BinarySI).String()
+}
+
+// RateHuman returns leak rate in human readable form
+func (s memoryStatus) RateHuman() string {
+	return resource.NewQuantity(s.Rate, resource.BinarySI).String()
+}
+
+// LimitHuman returns cgroup limit in human readable form
+func (s memoryStatus) LimitHuman() string {
+	return resource.NewQuantity(s.Limit, resource.BinarySI).String()
+}
+
+// memoryHog allocates and holds memory
+type memoryHog struct {
+	sync.Mutex
+	blocks [][]byte
+	held   int64
+	rate   int64
+	cancel context.CancelFunc
+	// increased by release, allocations started before are dropped
+	gen int
+}
+
+var memHog = &memoryHog{}
+
+// alloc allocates and holds n bytes, memory isn't held when it was released
+// or ctx was cancelled during allocation
+func (m *memoryHog) alloc(ctx context.Context, n int64) {
+	m.Lock()
+	gen := m.gen
+	m.Unlock()
+
+	b := make([]byte, n)
+
+	// touch every page so memory is really used
+	for i := 0; i < len(b); i += 4096 {
+		b[i] = 1
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if ctx.Err() != nil || gen != m.gen {
+		return
+	}
+
+	m.blocks = append(m.blocks, b)
+	m.held += n
+	memoryHeld.Set(float64(m.held))
+}
+
+// leak allocates rate bytes every second until released
+func (m *memoryHog) leak(rate int64) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.Lock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.cancel = cancel
+	m.rate = rate
+	m.Unlock()
+
+	go func() {
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				m.alloc(ctx, rate)
+			}
+		}
+	}()
+}
+
+// release frees all held memory and stops leaking
+func (m *memoryHog) release() {
+	m.Lock()
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	m.gen++
+	m.blocks = nil
+	m.held = 0
+	m.rate = 0
+	memoryHeld.Set(0)
+	m.Unlock()
+
+	debug.FreeOSMemory()
+}
+
+func (m *memoryHog) status() memoryStatus {
+	m.Lock()
+	defer m.Unlock()
+
+	l, _ := cgroupMemoryLimit()
+
+	return memoryStatus{Held: m.held, Rate: m.rate, Limit: l}
+}
+
+// parseMemorySize reads quantity (e.g. 100Mi) or fraction of cgroup limit,
+// size can't exceed the limit or maxMemorySize without limit
+func parseMemorySize(size, fraction string) (int64, error) {
+	if fraction != "" {
+		f, err := strconv.ParseFloat(fraction, 64)
+		if err != nil || f <= 0 || f > 1 {
+			return 0, fmt.Errorf("Fraction must be between 0 and 1")
+		}
+
+		l, err := cgroupMemoryLimit()
+		if err != nil {
+			return 0, err
+		}
+		if l == 0 {
+			return 0, fmt.Errorf("Container has no memory limit, use size instead of fraction")
+		}
+
+		return int64(float64(l) * f), nil
+	}
+
+	q, err := resource.ParseQuantity(size)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid size %s: %s", size, err)
+	}
+	if q.Value() <= 0 {
+		return 0, fmt.Errorf("Size must be positive")
+	}
+
+	max := int64(maxMemorySize)
+	if l, err := cgroupMemoryLimit(); err == nil && l > 0 {
+		max = l
+	}
+	if q.Value() > max {
+		return 0, fmt.Errorf("Size must not exceed %s", resource.NewQuantity(max, resource.BinarySI))
+	}
+
+	return q.Value(), nil
+}
+
+// memoryHandler allocates memory
+//
+// Query parameter size (e.g. 100Mi) or fraction (of cgroup limit) sets amount
+// allocated immediately, rate (e.g. 10Mi) starts leaking rate bytes per second.
+func memoryHandler(w http.ResponseWriter, r *http.Request) {
+	_, span := tracer.Start(r.Context(), "memory")
+	defer span.End()
+
+	q := r.URL.Query()
+
+	if q.Get("size") != "" || q.Get("fraction") != "" {
+		n, err := parseMemorySize(q.Get("size"), q.Get("fraction"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		memHog.alloc(context.Background(), n)
+		log.Printf("Allocated %d bytes of memory", n)
+	}
+
+	if rs := q.Get("rate"); rs != "" {
+		rate, err := parseMemorySize(rs, "")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		memHog.leak(rate)
+		log.Printf("Leaking %d bytes of memory per second", rate)
+	}
+
+	s := memHog.status()
+	fmt.Fprintf(w, "Holding %s of memory, growing by %s/s\n", s.HeldHuman(), s.RateHuman())
+}
+
+// memoryAdminHandler reports or releases held memory
+//
+//	GET    /memory  memory status
+//	DELETE /memory  release memory and stop leaking
+func memoryAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		memHog.release()
+		log.Printf("Memory released on request from %s", r.RemoteAddr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(memHog.status()); err != nil {
+		log.Printf("Unable to encode memory status: %s", err)
+	}
+}
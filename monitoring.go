@@ -35,6 +35,131 @@ var randomMet = prometheus.NewCounterVec(
 	[]string{"rn"},
 )
 
+var configReloads = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "config_reloads_total",
+		Help: "Number of configuration file reloads",
+	},
+	[]string{"result"},
+)
+
+var faultsInjected = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "faults_injected_total",
+		Help: "Number of requests failed by fault injection",
+	},
+	[]string{"route"},
+)
+
+var loadJobsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "load_jobs_active",
+	Help: "Number of running CPU load jobs",
+})
+
+var memoryHeld = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "memory_held_bytes",
+	Help: "Memory allocated and held by /memory endpoint",
+})
+
+var diskWritten = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "disk_written_bytes_total",
+	Help: "Bytes written into data directory by disk stress",
+})
+
+var diskRead = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "disk_read_bytes_total",
+	Help: "Bytes read from data directory by disk stress",
+})
+
+var diskFilled = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "disk_filled_bytes",
+	Help: "Size of files written into data directory by /disk/fill",
+})
+
+var diskErrors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "disk_errors_total",
+		Help: "Disk stress errors",
+	},
+	[]string{"error"},
+)
+
+var kubernetesAPIRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "kubernetes_api_requests_total",
+		Help: "Number of requests sent to kubernetes API server",
+	},
+	[]string{"method", "code"},
+)
+
+var clientHealthy = prometheus.NewGaugeFunc(
+	prometheus.GaugeOpts{
+		Name: "clients_healthy",
+		Help: "Number of healthy long-lived clients (kubernetes, redis)",
+	},
+	func() float64 {
+		n := 0
+		for _, h := range clientsHealth() {
+			if h.Healthy {
+				n++
+			}
+		}
+		return float64(n)
+	},
+)
+
+// redisPoolCollector exports connection pool stats of shared redis client
+type redisPoolCollector struct{}
+
+var (
+	redisPoolConnsDesc    = prometheus.NewDesc("redis_pool_connections", "Number of connections in redis pool", []string{"state"}, nil)
+	redisPoolRequestsDesc = prometheus.NewDesc("redis_pool_requests_total", "Number of redis pool connection requests", []string{"result"}, nil)
+)
+
+func (redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- redisPoolConnsDesc
+	ch <- redisPoolRequestsDesc
+}
+
+func (redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := redisClient.stats()
+	if s == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(redisPoolConnsDesc, prometheus.GaugeValue, float64(s.TotalConns), "total")
+	ch <- prometheus.MustNewConstMetric(redisPoolConnsDesc, prometheus.GaugeValue, float64(s.IdleConns), "idle")
+	ch <- prometheus.MustNewConstMetric(redisPoolRequestsDesc, prometheus.CounterValue, float64(s.Hits), "hit")
+	ch <- prometheus.MustNewConstMetric(redisPoolRequestsDesc, prometheus.CounterValue, float64(s.Misses), "miss")
+	ch <- prometheus.MustNewConstMetric(redisPoolRequestsDesc, prometheus.CounterValue, float64(s.Timeouts), "timeout")
+	ch <- prometheus.MustNewConstMetric(redisPoolRequestsDesc, prometheus.CounterValue, float64(s.StaleConns), "stale")
+}
+
+var liveStreams = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "live_streams",
+		Help: "Number of connected live dashboard streams",
+	},
+)
+
+var chaosKills = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "chaos_pods_killed_total",
+		Help: "Number of pods picked by chaos loop, result is killed, dry-run or failed",
+	},
+	[]string{"namespace", "result"},
+)
+
+var chaosRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "chaos_running",
+	Help: "Chaos loop is running",
+})
+
+var leaderElected = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "leader_election_leader",
+	Help: "Instance is leader of lease",
+})
+
 func init() {
 	err := prometheus.Register(pageHits)
 	if err != nil {
@@ -55,4 +180,63 @@ func init() {
 	if err != nil {
 		log.Printf("Unable to register pageHits: %s", err)
 	}
+
+	err = prometheus.Register(configReloads)
+	if err != nil {
+		log.Printf("Unable to register configReloads: %s", err)
+	}
+
+	err = prometheus.Register(faultsInjected)
+	if err != nil {
+		log.Printf("Unable to register faultsInjected: %s", err)
+	}
+
+	err = prometheus.Register(loadJobsActive)
+	if err != nil {
+		log.Printf("Unable to register loadJobsActive: %s", err)
+	}
+
+	err = prometheus.Register(memoryHeld)
+	if err != nil {
+		log.Printf("Unable to register memoryHeld: %s", err)
+	}
+
+	for _, c := range []prometheus.Collector{diskWritten, diskRead, diskFilled, diskErrors} {
+		err = prometheus.Register(c)
+		if err != nil {
+			log.Printf("Unable to register disk metrics: %s", err)
+		}
+	}
+
+	err = prometheus.Register(kubernetesAPIRequests)
+	if err != nil {
+		log.Printf("Unable to register kubernetesAPIRequests: %s", err)
+	}
+
+	err = prometheus.Register(clientHealthy)
+	if err != nil {
+		log.Printf("Unable to register clientHealthy: %s", err)
+	}
+
+	err = prometheus.Register(redisPoolCollector{})
+	if err != nil {
+		log.Printf("Unable to register redis pool metrics: %s", err)
+	}
+
+	err = prometheus.Register(liveStreams)
+	if err != nil {
+		log.Printf("Unable to register liveStreams: %s", err)
+	}
+
+	err = prometheus.Register(leaderElected)
+	if err != nil {
+		log.Printf("Unable to register leaderElected: %s", err)
+	}
+
+	for _, c := range []prometheus.Collector{chaosKills, chaosRunning} {
+		err = prometheus.Register(c)
+		if err != nil {
+			log.Printf("Unable to register chaos metrics: %s", err)
+		}
+	}
 }
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	discovery_v1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// timeout of info request to one peer
+const peerTimeout = 2 * time.Second
+
+// path of instance info on admin port of peer
+const instancePath = "/api/v1/instance"
+
+// peers are read at most once per period, requests in between get last view
+const peersCacheTTL = 5 * time.Second
+
+// version is set on build with -ldflags "-X main.version=1.2.3", revision of
+// commit is used when it's not set
+var version = ""
+
+// started is time this instance started, shown as uptime
+var started = time.Now()
+
+// buildVersion returns version of binary
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+
+	v, dirty := "dev", false
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v = s.Value
+			if len(v) > 8 {
+				v = v[:8]
+			}
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if dirty {
+		v += "-dirty"
+	}
+
+	return v
+}
+
+// peersConfig describes how replicas of kad are found, endpoints of service
+// are preferred over DNS
+type peersConfig struct {
+	// service in namespace of kad, its EndpointSlices list peers
+	Service string
+	// headless service name resolved to address of every peer
+	DNS string
+	// admin port of peers, instance info is read from it
+	Port int
+}
+
+// instanceInfo describes this instance to peers
+type instanceInfo struct {
+	Hostname string    `json:"hostname"`
+	Node     string    `json:"node,omitempty"`
+	Color    string    `json:"color"`
+	Version  string    `json:"version"`
+	Ready    bool      `json:"ready"`
+	Hits     int       `json:"hits"`
+	Started  time.Time `json:"started"`
+	Uptime   string    `json:"uptime"`
+}
+
+func newInstanceInfo(pc pageContent) instanceInfo {
+	return instanceInfo{
+		Hostname: pc.Hostname,
+		Node:     os.Getenv("NODE_NAME"),
+		Color:    pc.Color,
+		Version:  buildVersion(),
+		Ready:    pc.Ready,
+		Hits:     pc.Hits,
+		Started:  started,
+		Uptime:   time.Since(started).Round(time.Second).String(),
+	}
+}
+
+// readInstanceInfo returns info of this instance without building page
+// content, peers poll it often
+func readInstanceInfo() instanceInfo {
+	cfg := state.config()
+
+	pc := pageContent{Hostname: state.hostname(), Color: cfg.Color, Ready: serving()}
+	// redis error is shown on page, peers see hits as zero
+	pc.Hits, _ = currentHits(cfg.RedisServer)
+
+	return newInstanceInfo(pc)
+}
+
+// instanceHandler returns info of this instance, it's served on admin port
+// to bypass latency and faults of client port
+//
+//	GET /api/v1/instance
+func instanceHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, readInstanceInfo())
+}
+
+// peer is instance found by discovery, info is empty when request failed
+type peer struct {
+	Address string `json:"address"`
+	// pod behind endpoint, empty with DNS
+	Pod string `json:"pod,omitempty"`
+	// endpoint is ready as seen by kubernetes
+	EndpointReady bool   `json:"endpointReady"`
+	Self          bool   `json:"self"`
+	Error         string `json:"error,omitempty"`
+
+	instanceInfo
+}
+
+// peersView is content of peersPage
+type peersView struct {
+	Source   string   `json:"source"`
+	Error    string   `json:"error,omitempty"`
+	Peers    []peer   `json:"peers"`
+	Versions []string `json:"versions"`
+	Ready    int      `json:"ready"`
+}
+
+// Mixed reports if peers run different versions, e.g. during rollout
+func (v peersView) Mixed() bool {
+	return len(v.Versions) > 1
+}
+
+// discoverPeers returns peers without their info
+func discoverPeers(ctx context.Context, cfg peersConfig, ns string) ([]peer, error) {
+	if cfg.Service == "" {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, cfg.DNS)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to resolve %s: %s", cfg.DNS, err)
+		}
+
+		peers := []peer{}
+		for _, a := range addrs {
+			peers = append(peers, peer{Address: net.JoinHostPort(a, strconv.Itoa(cfg.Port)), EndpointReady: true})
+		}
+
+		return peers, nil
+	}
+
+	cs, err := getClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	slices, err := cs.DiscoveryV1().EndpointSlices(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: discovery_v1.LabelServiceName + "=" + cfg.Service,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list endpoints of service %s: %s", cfg.Service, err)
+	}
+
+	// endpoints list client port, admin port of same pod is used instead
+	peers := []peer{}
+	for _, s := range slices.Items {
+		for _, e := range s.Endpoints {
+			if len(e.Addresses) == 0 {
+				continue
+			}
+
+			p := peer{
+				Address:       net.JoinHostPort(e.Addresses[0], strconv.Itoa(cfg.Port)),
+				EndpointReady: e.Conditions.Ready == nil || *e.Conditions.Ready,
+			}
+			if e.TargetRef != nil {
+				p.Pod = e.TargetRef.Name
+			}
+			peers = append(peers, p)
+		}
+	}
+
+	return peers, nil
+}
+
+// fetchPeer reads instance info of peer
+func fetchPeer(ctx context.Context, p *peer) {
+	ctx, cancel := context.WithTimeout(ctx, peerTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+p.Address+instancePath, nil)
+	if err != nil {
+		p.Error = err.Error()
+		return
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		p.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		p.Error = "unexpected status " + resp.Status
+		return
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&p.instanceInfo); err != nil {
+		p.Error = "unable to parse info: " + err.Error()
+	}
+}
+
+// peersCache holds last view of peers, lock is held while peers are read so
+// concurrent requests wait for one read
+type peersCache struct {
+	sync.Mutex
+	view peersView
+	read time.Time
+}
+
+var peersViewCache = &peersCache{}
+
+// get returns view of peers read less than peersCacheTTL ago or reads it,
+// view of cancelled request isn't kept
+func (c *peersCache) get(ctx context.Context) peersView {
+	c.Lock()
+	defer c.Unlock()
+
+	if !c.read.IsZero() && time.Since(c.read) < peersCacheTTL {
+		return c.view
+	}
+
+	pv := readPeers(ctx)
+	if ctx.Err() == nil {
+		c.view, c.read = pv, time.Now()
+	}
+
+	return pv
+}
+
+// readPeers discovers peers and reads their info in parallel
+func readPeers(ctx context.Context) peersView {
+	ctx, span := tracer.Start(ctx, "read-peers")
+	defer span.End()
+
+	cfg := state.config()
+	pv := peersView{Peers: []peer{}, Versions: []string{}}
+
+	switch {
+	case cfg.Peers.Service != "":
+		pv.Source = "endpoints of service " + cfg.Namespace + "/" + cfg.Peers.Service
+	case cfg.Peers.DNS != "":
+		pv.Source = "DNS " + cfg.Peers.DNS
+	default:
+		pv.Error = "Peer discovery is not configured, set peers.service or peers.dns"
+		return pv
+	}
+
+	peers, err := discoverPeers(ctx, cfg.Peers, cfg.Namespace)
+	if err != nil {
+		span.RecordError(err)
+		pv.Error = err.Error()
+		return pv
+	}
+	span.SetAttributes(attribute.Int("peers", len(peers)))
+
+	wg := sync.WaitGroup{}
+	for i := range peers {
+		wg.Add(1)
+		go func(p *peer) {
+			defer wg.Done()
+			fetchPeer(ctx, p)
+		}(&peers[i])
+	}
+	wg.Wait()
+
+	hn := state.hostname()
+	for i, p := range peers {
+		peers[i].Self = p.Hostname != "" && p.Hostname == hn
+		if p.Ready {
+			pv.Ready++
+		}
+		if p.Version != "" && !contains(pv.Versions, p.Version) {
+			pv.Versions = append(pv.Versions, p.Version)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Address < peers[j].Address
+	})
+	sort.Strings(pv.Versions)
+	pv.Peers = peers
+
+	return pv
+}
+
+// peersHandler shows info of every peer, JSON is returned for Accept:
+// application/json. Peers are read at most once per peersCacheTTL.
+//
+//	GET /peers
+func peersHandler(w http.ResponseWriter, r *http.Request) {
+	pv := peersViewCache.get(r.Context())
+
+	if wantsJSON(r) {
+		writeJSON(w, pv)
+		return
+	}
+
+	t, err := template.New("peers").Parse(peersPage)
+	if err != nil {
+		log.Printf("Unable to parse template: %s", err)
+		http.Error(w, "Unable to parse template", http.StatusInternalServerError)
+		return
+	}
+	if err := t.Execute(w, pv); err != nil {
+		log.Printf("Unable to execute template: %s", err)
+	}
+}
+
+// peersPage is table of peers, it's refreshed periodically
+var peersPage = `
+<html>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<head>
+<title>Peers</title>
+<link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0-alpha1/dist/css/bootstrap.min.css" rel="stylesheet" integrity="sha384-GLhlTQ8iRABdZLl6O3oVMWSktQOp6b7In1Zl3/Jr59b6EGGoI1aFkw7cmDA6j6gD" crossorigin="anonymous">
+<style>
+body {
+	padding: 10px;
+}
+span.color {
+	display: inline-block;
+	width: 1em;
+	height: 1em;
+	border: 1px solid #ccc;
+	vertical-align: middle;
+}
+</style>
+</head>
+<body>
+<div class="container">
+
+<p><a href="/">&larr; back</a> Peers found by {{ .Source }}
+<span class="badge bg-secondary">{{ .Ready }}/{{ len .Peers }} ready</span>
+{{ if .Mixed }}<span class="badge bg-warning">mixed versions</span>{{ end }}
+</p>
+
+{{ if .Error }}
+<div class="alert alert-danger">{{ .Error }}</div>
+{{ end }}
+
+<table class="table table-sm table-hover">
+<thead>
+<tr><th>Address</th><th>Hostname</th><th>Node</th><th>Color</th><th>Version</th><th>Ready</th><th>Hits</th><th>Uptime</th></tr>
+</thead>
+<tbody>
+{{ range .Peers }}
+<tr{{ if .Self }} class="table-info"{{ end }}>
+	<td><code>{{ .Address }}</code>{{ if not .EndpointReady }} <span class="badge bg-secondary" title="endpoint isn't ready">not serving</span>{{ end }}</td>
+	{{ if .Error }}
+	<td colspan="7"><span class="badge bg-danger">unreachable</span> {{ .Error }}</td>
+	{{ else }}
+	<td>{{ .Hostname }}{{ if .Self }} <span class="badge bg-info">this</span>{{ end }}</td>
+	<td>{{ .Node }}</td>
+	<td><span class="color" style="background-color: {{ .Color }}"></span> {{ .Color }}</td>
+	<td><code>{{ .Version }}</code></td>
+	<td>{{ if .Ready }}<span class="badge bg-success">yes</span>{{ else }}<span class="badge bg-danger">no</span>{{ end }}</td>
+	<td>{{ .Hits }}</td>
+	<td>{{ .Uptime }}</td>
+	{{ end }}
+</tr>
+{{ end }}
+</tbody>
+</table>
+
+</div>
+</body>
+</html>
+`
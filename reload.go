@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+// settings which are applied only on startup
+var restartSettings = []string{"listen", "listenAdmin", "namespace", "namespaces", "namespaceSelector", "tracing.jaegerAgentHost", "leaderElection.enabled", "leaderElection.namespace", "leaderElection.lease"}
+
+// reloadConfig loads configuration file again and applies it to state
+func reloadConfig(path string, flags *pflag.FlagSet) error {
+	cfg, settings, err := loadConfig(path, flags)
+	if err != nil {
+		configReloads.WithLabelValues("failure").Inc()
+
+		state.Lock()
+		state.ReloadError = err.Error()
+		state.Unlock()
+
+		return err
+	}
+
+	state.Lock()
+	defer state.Unlock()
+
+	old := state.Config
+	oldSettings := map[string]configValue{}
+	for _, v := range state.Settings {
+		oldSettings[v.Name] = v
+	}
+
+	// keep values which can't be changed without restart
+	cfg.Listen = old.Listen
+	cfg.ListenAdmin = old.ListenAdmin
+	cfg.Namespace = old.Namespace
+	cfg.Namespaces = old.Namespaces
+	cfg.NamespaceSelector = old.NamespaceSelector
+	cfg.JaegerAgentHost = old.JaegerAgentHost
+	cfg.LeaderElection = old.LeaderElection
+	cfg.Endpoints = old.Endpoints
+
+	for i, v := range settings {
+		ov, ok := oldSettings[v.Name]
+		if !ok || ov.Value == v.Value {
+			continue
+		}
+
+		if contains(restartSettings, v.Name) || strings.HasPrefix(v.Name, "endpoints.") {
+			log.Printf("Config %s changed to %s, restart is required to apply it", v.Name, v.Value)
+			settings[i] = ov
+			continue
+		}
+
+		log.Printf("Config %s changed: %s -> %s (%s)", v.Name, ov.Value, v.Value, v.Source)
+	}
+
+	state.Config = cfg
+	state.Settings = settings
+	state.ReloadError = ""
+	state.Reloaded = time.Now()
+
+	configReloads.WithLabelValues("success").Inc()
+	events.notify()
+
+	// loop started from admin API is replaced only when chaos section changes
+	if cfg.Chaos != old.Chaos {
+		chaos.configure(cfg.Chaos)
+	}
+
+	return nil
+}
+
+// watchConfig reloads configuration when file at path changes
+//
+// Directory of the file is watched because kubelet updates ConfigMap volumes
+// by swapping ..data symlink, file itself is never written.
+func watchConfig(ctx context.Context, path string, flags *pflag.FlagSet) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := w.Add(filepath.Dir(path)); err != nil {
+		w.Close()
+		return err
+	}
+
+	last, _ := ioutil.ReadFile(path)
+
+	go func() {
+		defer w.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Config watcher error: %s", err)
+
+			case _, ok := <-w.Events:
+				if !ok {
+					return
+				}
+
+				// compare content, symlink swap emits several events
+				content, err := ioutil.ReadFile(path)
+				if err == nil && bytes.Equal(content, last) {
+					continue
+				}
+				last = content
+
+				log.Printf("Config file %s changed, reloading", path)
+				if err := reloadConfig(path, flags); err != nil {
+					log.Printf("Config reload failed: %s", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	autoscaling_v1 "k8s.io/api/autoscaling/v1"
+	batch_v1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	networking_v1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// resourceKind describes kind of resource shown on page
+type resourceKind struct {
+	// short name used in URLs, config and page classes, e.g. sts
+	name string
+	// kind shown on page, e.g. statefulset
+	kind string
+	// title of list on page
+	title string
+	// API group and resource used in RBAC checks
+	group    string
+	resource string
+	// core kinds are always watched, other kinds are watched only when
+	// role allows to list and watch them
+	core bool
+	// pods of kind have logs shown by log viewer
+	logs bool
+
+	informer func(f informers.SharedInformerFactory) cache.SharedIndexInformer
+	// summary shown under name, nil for events which have own table
+	details func(o interface{}) string
+	// rollout is nil when kind has no rollout actions
+	rollout func(o interface{}) *rolloutInfo
+	// labels and delete are nil when kind can't be deleted
+	labels func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error)
+	delete func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error
+}
+
+// deletable reports if resources of kind can be deleted from page
+func (k resourceKind) deletable() bool {
+	return k.delete != nil
+}
+
+// resourceKinds is registry of kinds in order shown on page
+var resourceKinds = []resourceKind{
+	{
+		name: "pod", kind: "pod", title: "Pods", resource: "pods", core: true, logs: true,
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Core().V1().Pods().Informer()
+		},
+		details: func(o interface{}) string {
+			p := o.(*v1.Pod)
+			s := fmt.Sprintf("%s, %s ready, %d restarts", podStatus(*p), podReady(*p), podRestarts(*p))
+			if n := p.Spec.NodeName; n != "" {
+				s += ", " + n
+			}
+			if ip := p.Status.PodIP; ip != "" {
+				s += ", " + ip
+			}
+			return s
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.CoreV1().Pods(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.CoreV1().Pods(ns).Delete(ctx, name, do)
+		},
+	},
+	{
+		name: "deploy", kind: "deployment", title: "Deployments", group: "apps", resource: "deployments", core: true,
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Apps().V1().Deployments().Informer()
+		},
+		details: func(o interface{}) string {
+			d := o.(*apps_v1.Deployment)
+			s := fmt.Sprintf("desired %d, updated %d, available %d, ready %d", replicas(d.Spec.Replicas), d.Status.UpdatedReplicas, d.Status.AvailableReplicas, d.Status.ReadyReplicas)
+			if d.Spec.Paused {
+				s += ", paused"
+			}
+			return s
+		},
+		rollout: func(o interface{}) *rolloutInfo {
+			d := o.(*apps_v1.Deployment)
+			ri := &rolloutInfo{Paused: d.Spec.Paused, Containers: []rolloutContainer{}}
+			for _, c := range d.Spec.Template.Spec.Containers {
+				ri.Containers = append(ri.Containers, rolloutContainer{Name: c.Name, Image: c.Image})
+			}
+			return ri
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.AppsV1().Deployments(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.AppsV1().Deployments(ns).Delete(ctx, name, do)
+		},
+	},
+	{
+		name: "rs", kind: "replicaset", title: "ReplicaSets", group: "apps", resource: "replicasets", core: true,
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Apps().V1().ReplicaSets().Informer()
+		},
+		details: func(o interface{}) string {
+			r := o.(*apps_v1.ReplicaSet)
+			return fmt.Sprintf("ready %d/%d", r.Status.ReadyReplicas, replicas(r.Spec.Replicas))
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.AppsV1().ReplicaSets(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.AppsV1().ReplicaSets(ns).Delete(ctx, name, do)
+		},
+	},
+	{
+		name: "svc", kind: "service", title: "Services", resource: "services", core: true,
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Core().V1().Services().Informer()
+		},
+		details: func(o interface{}) string {
+			svc := o.(*v1.Service)
+			s := string(svc.Spec.Type)
+			switch ip := svc.Spec.ClusterIP; ip {
+			case "":
+			case v1.ClusterIPNone:
+				s += " headless"
+			default:
+				s += " " + ip
+			}
+			for _, p := range svc.Spec.Ports {
+				s += fmt.Sprintf(", %d/%s", p.Port, p.Protocol)
+			}
+			return s
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.CoreV1().Services(ns).Delete(ctx, name, do)
+		},
+	},
+	{
+		name: "event", kind: "event", title: "Events", resource: "events", core: true,
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Core().V1().Events().Informer()
+		},
+	},
+	{
+		name: "sts", kind: "statefulset", title: "StatefulSets", group: "apps", resource: "statefulsets",
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Apps().V1().StatefulSets().Informer()
+		},
+		details: func(o interface{}) string {
+			s := o.(*apps_v1.StatefulSet)
+			return fmt.Sprintf("ready %d/%d", s.Status.ReadyReplicas, replicas(s.Spec.Replicas))
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.AppsV1().StatefulSets(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.AppsV1().StatefulSets(ns).Delete(ctx, name, do)
+		},
+	},
+	{
+		name: "ds", kind: "daemonset", title: "DaemonSets", group: "apps", resource: "daemonsets",
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Apps().V1().DaemonSets().Informer()
+		},
+		details: func(o interface{}) string {
+			d := o.(*apps_v1.DaemonSet)
+			return fmt.Sprintf("ready %d/%d, updated %d", d.Status.NumberReady, d.Status.DesiredNumberScheduled, d.Status.UpdatedNumberScheduled)
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.AppsV1().DaemonSets(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.AppsV1().DaemonSets(ns).Delete(ctx, name, do)
+		},
+	},
+	{
+		name: "job", kind: "job", title: "Jobs", group: "batch", resource: "jobs",
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Batch().V1().Jobs().Informer()
+		},
+		details: func(o interface{}) string {
+			j := o.(*batch_v1.Job)
+			return fmt.Sprintf("succeeded %d/%d, active %d, failed %d", j.Status.Succeeded, replicas(j.Spec.Completions), j.Status.Active, j.Status.Failed)
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.BatchV1().Jobs(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.BatchV1().Jobs(ns).Delete(ctx, name, do)
+		},
+	},
+	{
+		name: "cronjob", kind: "cronjob", title: "CronJobs", group: "batch", resource: "cronjobs",
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Batch().V1().CronJobs().Informer()
+		},
+		details: func(o interface{}) string {
+			c := o.(*batch_v1.CronJob)
+			s := c.Spec.Schedule
+			if c.Spec.Suspend != nil && *c.Spec.Suspend {
+				s += ", suspended"
+			}
+			if t := c.Status.LastScheduleTime; t != nil {
+				s += ", last " + age(t.Time)
+			}
+			return s
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.BatchV1().CronJobs(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.BatchV1().CronJobs(ns).Delete(ctx, name, do)
+		},
+	},
+	{
+		name: "cm", kind: "configmap", title: "ConfigMaps", resource: "configmaps",
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Core().V1().ConfigMaps().Informer()
+		},
+		details: func(o interface{}) string {
+			c := o.(*v1.ConfigMap)
+			return fmt.Sprintf("%d keys", len(c.Data)+len(c.BinaryData))
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.CoreV1().ConfigMaps(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.CoreV1().ConfigMaps(ns).Delete(ctx, name, do)
+		},
+	},
+	{
+		name: "ing", kind: "ingress", title: "Ingresses", group: "networking.k8s.io", resource: "ingresses",
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Networking().V1().Ingresses().Informer()
+		},
+		details: func(o interface{}) string {
+			i := o.(*networking_v1.Ingress)
+			hosts := []string{}
+			for _, r := range i.Spec.Rules {
+				if r.Host != "" && !contains(hosts, r.Host) {
+					hosts = append(hosts, r.Host)
+				}
+			}
+			if len(hosts) == 0 {
+				hosts = append(hosts, "*")
+			}
+			s := strings.Join(hosts, ", ")
+			if c := i.Spec.IngressClassName; c != nil {
+				s += ", class " + *c
+			}
+			return s
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.NetworkingV1().Ingresses(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.NetworkingV1().Ingresses(ns).Delete(ctx, name, do)
+		},
+	},
+	{
+		name: "pvc", kind: "persistentvolumeclaim", title: "PersistentVolumeClaims", resource: "persistentvolumeclaims",
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Core().V1().PersistentVolumeClaims().Informer()
+		},
+		details: func(o interface{}) string {
+			p := o.(*v1.PersistentVolumeClaim)
+			s := string(p.Status.Phase)
+			if c, ok := p.Status.Capacity[v1.ResourceStorage]; ok {
+				s += ", " + c.String()
+			}
+			if c := p.Spec.StorageClassName; c != nil {
+				s += ", " + *c
+			}
+			return s
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.CoreV1().PersistentVolumeClaims(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.CoreV1().PersistentVolumeClaims(ns).Delete(ctx, name, do)
+		},
+	},
+	{
+		name: "hpa", kind: "horizontalpodautoscaler", title: "HorizontalPodAutoscalers", group: "autoscaling", resource: "horizontalpodautoscalers",
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Autoscaling().V1().HorizontalPodAutoscalers().Informer()
+		},
+		details: func(o interface{}) string {
+			h := o.(*autoscaling_v1.HorizontalPodAutoscaler)
+			s := fmt.Sprintf("%s %s, replicas %d (%d-%d)", strings.ToLower(h.Spec.ScaleTargetRef.Kind), h.Spec.ScaleTargetRef.Name, h.Status.CurrentReplicas, replicas(h.Spec.MinReplicas), h.Spec.MaxReplicas)
+			if c := h.Status.CurrentCPUUtilizationPercentage; c != nil {
+				s += fmt.Sprintf(", cpu %d%%", *c)
+			}
+			if t := h.Spec.TargetCPUUtilizationPercentage; t != nil {
+				s += fmt.Sprintf("/%d%%", *t)
+			}
+			return s
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.AutoscalingV1().HorizontalPodAutoscalers(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.AutoscalingV1().HorizontalPodAutoscalers(ns).Delete(ctx, name, do)
+		},
+	},
+	{
+		name: "netpol", kind: "networkpolicy", title: "NetworkPolicies", group: "networking.k8s.io", resource: "networkpolicies",
+		informer: func(f informers.SharedInformerFactory) cache.SharedIndexInformer {
+			return f.Networking().V1().NetworkPolicies().Informer()
+		},
+		details: func(o interface{}) string {
+			n := o.(*networking_v1.NetworkPolicy)
+			types := []string{}
+			for _, t := range n.Spec.PolicyTypes {
+				types = append(types, strings.ToLower(string(t)))
+			}
+			sel := metav1.FormatLabelSelector(&n.Spec.PodSelector)
+			if sel == "<none>" {
+				sel = "all pods"
+			}
+			return fmt.Sprintf("%s, %s", sel, strings.Join(types, ", "))
+		},
+		labels: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string) (map[string]string, error) {
+			o, err := cs.NetworkingV1().NetworkPolicies(ns).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return o.Labels, nil
+		},
+		delete: func(ctx context.Context, cs *kubernetes.Clientset, ns, name string, do metav1.DeleteOptions) error {
+			return cs.NetworkingV1().NetworkPolicies(ns).Delete(ctx, name, do)
+		},
+	},
+}
+
+// resourceKindByName returns kind registered under short name
+func resourceKindByName(name string) (resourceKind, bool) {
+	for _, k := range resourceKinds {
+		if k.name == name {
+			return k, true
+		}
+	}
+
+	return resourceKind{}, false
+}
+
+// deletableTypeNames returns sorted names of deletable resource kinds
+func deletableTypeNames() []string {
+	r := []string{}
+	for _, k := range resourceKinds {
+		if k.deletable() {
+			r = append(r, k.name)
+		}
+	}
+	sort.Strings(r)
+
+	return r
+}
+
+// kindAccessRules returns rules of kinds which aren't core, they are
+// checked to find out which kinds can be watched
+func kindAccessRules() []accessRule {
+	r := []accessRule{}
+	for _, k := range resourceKinds {
+		if k.core {
+			continue
+		}
+		r = append(r,
+			accessRule{Verb: "list", Group: k.group, Resource: k.resource},
+			accessRule{Verb: "watch", Group: k.group, Resource: k.resource},
+		)
+		if k.deletable() {
+			r = append(r, accessRule{Verb: "delete", Group: k.group, Resource: k.resource})
+		}
+	}
+
+	return r
+}
+
+// replicas returns value of optional count, 1 is default of API
+func replicas(r *int32) int32 {
+	if r == nil {
+		return 1
+	}
+
+	return *r
+}
+
+// rolloutContainer is container which image or color can be changed
+type rolloutContainer struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// rolloutInfo is state used by rollout actions on page
+type rolloutInfo struct {
+	Paused     bool               `json:"paused"`
+	Containers []rolloutContainer `json:"containers"`
+}
+
+// resourceObject is summary of resource shown on page
+type resourceObject struct {
+	Namespace string       `json:"namespace"`
+	Name      string       `json:"name"`
+	Details   string       `json:"details"`
+	Created   time.Time    `json:"created"`
+	Rollout   *rolloutInfo `json:"rollout,omitempty"`
+}
+
+// resourceList is list of resources of one kind
+type resourceList struct {
+	// short name of kind, e.g. sts
+	Name      string           `json:"name"`
+	Kind      string           `json:"kind"`
+	Title     string           `json:"-"`
+	Resource  string           `json:"resource"`
+	Deletable bool             `json:"deletable"`
+	Logs      bool             `json:"logs"`
+	Items     []resourceObject `json:"items"`
+}
+
+// In returns list with resources in namespace
+func (l resourceList) In(ns string) resourceList {
+	n := l
+	n.Items = nil
+	for _, i := range l.Items {
+		if i.Namespace == ns {
+			n.Items = append(n.Items, i)
+		}
+	}
+
+	return n
+}
+
+// listKind appends resources of kind in namespace from informer cache to l
+func listKind(k resourceKind, i cache.SharedIndexInformer, ns string, l *resourceList) error {
+	objs, err := i.GetIndexer().ByIndex(cache.NamespaceIndex, ns)
+	if err != nil {
+		return err
+	}
+
+	items := []resourceObject{}
+	for _, o := range objs {
+		m, err := meta.Accessor(o)
+		if err != nil {
+			return err
+		}
+		ro := resourceObject{
+			Namespace: m.GetNamespace(),
+			Name:      m.GetName(),
+			Details:   k.details(o),
+			Created:   m.GetCreationTimestamp().Time,
+		}
+		if k.rollout != nil {
+			ro.Rollout = k.rollout(o)
+		}
+		items = append(items, ro)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	l.Items = append(l.Items, items...)
+
+	return nil
+}
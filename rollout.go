@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	apps_v1 "k8s.io/api/apps/v1"
+	autoscaling_v1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// rollout actions on deployments
+const (
+	rolloutScale   = "scale"
+	rolloutRestart = "restart"
+	rolloutPause   = "pause"
+	rolloutResume  = "resume"
+	rolloutImage   = "image"
+	rolloutColor   = "color"
+
+	// upper limit of replicas set from page
+	maxReplicas = 50
+
+	// annotation set by kubectl rollout restart
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+)
+
+var rolloutActions = []string{rolloutScale, rolloutRestart, rolloutPause, rolloutResume, rolloutImage, rolloutColor}
+
+// rolloutRequest is body of rollout action, form fields have same names
+type rolloutRequest struct {
+	// scale to replicas or by delta (e.g. -1)
+	Replicas *int32 `json:"replicas,omitempty"`
+	Delta    int32  `json:"delta,omitempty"`
+	// container to update, may be omitted when deployment has single container
+	Container string `json:"container,omitempty"`
+	Image     string `json:"image,omitempty"`
+	Color     string `json:"color,omitempty"`
+}
+
+// rolloutStatus describes deployment after action
+type rolloutStatus struct {
+	Name          string            `json:"name"`
+	Action        string            `json:"action"`
+	Replicas      int32             `json:"replicas"`
+	ReadyReplicas int32             `json:"readyReplicas"`
+	Paused        bool              `json:"paused"`
+	Images        map[string]string `json:"images"`
+}
+
+func newRolloutStatus(d *apps_v1.Deployment, action string) rolloutStatus {
+	s := rolloutStatus{
+		Name:          d.Name,
+		Action:        action,
+		ReadyReplicas: d.Status.ReadyReplicas,
+		Paused:        d.Spec.Paused,
+		Images:        map[string]string{},
+	}
+	if d.Spec.Replicas != nil {
+		s.Replicas = *d.Spec.Replicas
+	}
+	for _, c := range d.Spec.Template.Spec.Containers {
+		s.Images[c.Name] = c.Image
+	}
+
+	return s
+}
+
+// isJSON reports if request body is JSON
+func isJSON(r *http.Request) bool {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	return mt == "application/json"
+}
+
+// readRolloutRequest reads action parameters from JSON body or form
+func readRolloutRequest(r *http.Request) (rolloutRequest, error) {
+	rr := rolloutRequest{}
+
+	if isJSON(r) {
+		if err := json.NewDecoder(r.Body).Decode(&rr); err != nil {
+			return rr, fmt.Errorf("Unable to parse request: %s", err)
+		}
+		return rr, nil
+	}
+
+	if v := r.PostFormValue("replicas"); v != "" {
+		i, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return rr, fmt.Errorf("Invalid replicas %s", v)
+		}
+		n := int32(i)
+		rr.Replicas = &n
+	}
+	if v := r.PostFormValue("delta"); v != "" {
+		i, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return rr, fmt.Errorf("Invalid delta %s", v)
+		}
+		rr.Delta = int32(i)
+	}
+	rr.Container = r.PostFormValue("container")
+	rr.Image = r.PostFormValue("image")
+	rr.Color = r.PostFormValue("color")
+
+	return rr, nil
+}
+
+// containerName returns container to update
+func containerName(d *apps_v1.Deployment, name string) (string, error) {
+	cs := d.Spec.Template.Spec.Containers
+
+	if name == "" {
+		if len(cs) != 1 {
+			return "", fmt.Errorf("Deployment has %d containers, set container", len(cs))
+		}
+		return cs[0].Name, nil
+	}
+
+	for _, c := range cs {
+		if c.Name == name {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("Container %s not found", name)
+}
+
+// rolloutPatch returns strategic merge patch for action
+func rolloutPatch(d *apps_v1.Deployment, action string, rr rolloutRequest) (map[string]interface{}, error) {
+	switch action {
+	case rolloutRestart:
+		return map[string]interface{}{
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"annotations": map[string]string{restartedAtAnnotation: time.Now().Format(time.RFC3339)},
+					},
+				},
+			},
+		}, nil
+
+	case rolloutPause, rolloutResume:
+		return map[string]interface{}{
+			"spec": map[string]interface{}{"paused": action == rolloutPause},
+		}, nil
+
+	case rolloutImage, rolloutColor:
+		c, err := containerName(d, rr.Container)
+		if err != nil {
+			return nil, err
+		}
+
+		container := map[string]interface{}{"name": c}
+		if action == rolloutImage {
+			if rr.Image == "" {
+				return nil, fmt.Errorf("Missing image")
+			}
+			container["image"] = rr.Image
+		} else {
+			if rr.Color == "" {
+				return nil, fmt.Errorf("Missing color")
+			}
+			// env is merged by name
+			container["env"] = []map[string]string{{"name": "COLOR", "value": rr.Color}}
+		}
+
+		return map[string]interface{}{
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{container},
+					},
+				},
+			},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown action %s", action)
+}
+
+// rolloutHandler runs rollout action on deployment
+//
+//	POST /kubernetes/deploy/{name}/{action}    action from page, CSRF token is required
+//	POST /api/v1/deployments/{name}/{action}  action with JSON body, returns deployment status
+//
+// Actions are scale (replicas or delta), restart, pause, resume, image
+// (image and container) and color (color and container). Namespace is set by
+// namespace query parameter or form field.
+func rolloutHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "rollout")
+	defer span.End()
+
+	vars := mux.Vars(r)
+	name, action := vars["name"], vars["action"]
+
+	span.SetAttributes(
+		attribute.String("resources.name", name),
+		attribute.String("rollout.action", action),
+	)
+
+	if !contains(rolloutActions, action) {
+		http.Error(w, fmt.Sprintf("Unknown action %s, use one of %v", action, rolloutActions), http.StatusBadRequest)
+		return
+	}
+
+	a := state.config().Actions
+	if err := a.rolloutAllowed(action); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !guardAction(w, r, a, fmt.Sprintf("Run %s on deployment %s?", action, name)) {
+		return
+	}
+
+	ns, err := requestNamespace(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	span.SetAttributes(attribute.String("resources.namespace", ns))
+
+	rr, err := readRolloutRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cs, err := getClientset()
+	if err != nil {
+		http.Error(w, "Can't connect to kubernetes", http.StatusBadRequest)
+		return
+	}
+
+	d, err := cs.AppsV1().Deployments(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		http.Error(w, "Failed reading deployment "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.labelsAllowed(d.Labels); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if action == rolloutScale {
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		if rr.Replicas != nil {
+			replicas = *rr.Replicas
+		}
+		replicas += rr.Delta
+
+		if replicas < 0 || replicas > maxReplicas {
+			http.Error(w, fmt.Sprintf("Replicas must be between 0 and %d", maxReplicas), http.StatusBadRequest)
+			return
+		}
+
+		sc := &autoscaling_v1.Scale{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: d.Namespace},
+			Spec:       autoscaling_v1.ScaleSpec{Replicas: replicas},
+		}
+		if _, err := cs.AppsV1().Deployments(ns).UpdateScale(ctx, name, sc, metav1.UpdateOptions{}); err != nil {
+			span.RecordError(err)
+			http.Error(w, "Failed scaling deployment "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		d.Spec.Replicas = &replicas
+		log.Printf("Deployment %s/%s scaled to %d on request from %s", ns, name, replicas, r.RemoteAddr)
+
+	} else {
+		p, err := rolloutPatch(d, action, rr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, err := json.Marshal(p)
+		if err != nil {
+			http.Error(w, "Unable to encode patch: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		d, err = cs.AppsV1().Deployments(ns).Patch(ctx, name, types.StrategicMergePatchType, data, metav1.PatchOptions{})
+		if err != nil {
+			span.RecordError(err)
+			http.Error(w, "Failed patching deployment "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Deployment %s/%s %s on request from %s", ns, name, action, r.RemoteAddr)
+	}
+
+	if isJSON(r) || wantsJSON(r) {
+		writeJSON(w, newRolloutStatus(d, action))
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// stopping is cancelled when servers are closed, it ends streams which would
+// keep them open
+var stopping, stopStreams = context.WithCancel(context.Background())
+
+// gracefulShutdown reports instance as not ready, keeps serving requests for
+// drain period and then shuts servers down waiting at most timeout for
+// in-flight requests
+func gracefulShutdown(servers []*http.Server, drain, timeout time.Duration) {
+	start := time.Now()
+
+	// pods are not killed by instance which is going away, other instance
+	// takes over lease immediately
+	chaos.stop()
+	leader.resign()
+
+	log.Printf("Shutdown: reporting this instance as NOT ready")
+	terminating.Store(true)
+	events.notify()
+
+	if drain > 0 {
+		log.Printf("Shutdown: draining for %s", drain)
+		time.Sleep(drain)
+		log.Printf("Shutdown: drain finished after %s", time.Since(start).Round(time.Millisecond))
+	}
+
+	shutdownServers(servers, timeout)
+
+	log.Printf("Shutdown: finished after %s", time.Since(start).Round(time.Millisecond))
+}
+
+// shutdownServers stops servers in parallel
+func shutdownServers(servers []*http.Server, timeout time.Duration) {
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Printf("Shutdown: closing servers, waiting up to %s for in-flight requests", timeout)
+
+	// live dashboard and log streams never finish on their own
+	events.close()
+	stopStreams()
+
+	wg := sync.WaitGroup{}
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s *http.Server) {
+			defer wg.Done()
+
+			if err := s.Shutdown(ctx); err != nil {
+				log.Printf("Shutdown: server %s failed to shut down: %s", s.Addr, err)
+				return
+			}
+			log.Printf("Shutdown: server %s closed after %s", s.Addr, time.Since(start).Round(time.Millisecond))
+		}(s)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// number of most recent events shown on page
+const maxEvents = 20
+
+// functions available in page template
+var templateFuncs = template.FuncMap{
+	"age":       age,
+	"eventTime": eventTime,
+}
+
+// podStatus returns pod status as shown by kubectl, e.g. Running,
+// CrashLoopBackOff or Terminating
+func podStatus(p v1.Pod) string {
+	if p.DeletionTimestamp != nil {
+		return "Terminating"
+	}
+
+	for _, cs := range append(p.Status.InitContainerStatuses, p.Status.ContainerStatuses...) {
+		if w := cs.State.Waiting; w != nil && w.Reason != "" && w.Reason != "PodInitializing" {
+			return w.Reason
+		}
+		if t := cs.State.Terminated; t != nil && t.Reason != "" && t.Reason != "Completed" {
+			return t.Reason
+		}
+	}
+
+	if p.Status.Reason != "" {
+		return p.Status.Reason
+	}
+
+	return string(p.Status.Phase)
+}
+
+// podReady returns ready and total containers, e.g. 1/2
+func podReady(p v1.Pod) string {
+	ready := 0
+	for _, cs := range p.Status.ContainerStatuses {
+		if cs.Ready {
+			ready++
+		}
+	}
+
+	return fmt.Sprintf("%d/%d", ready, len(p.Spec.Containers))
+}
+
+// podRestarts returns sum of container restarts
+func podRestarts(p v1.Pod) int32 {
+	var r int32
+	for _, cs := range p.Status.ContainerStatuses {
+		r += cs.RestartCount
+	}
+
+	return r
+}
+
+// age returns time since t in short form as kubectl does, e.g. 5m or 3d
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// eventTime returns time event was last seen
+func eventTime(e v1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	default:
+		return e.CreationTimestamp.Time
+	}
+}
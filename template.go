@@ -26,12 +26,26 @@ div.doc {
 	font-weight: 400;
 }
 
-li.pod a, li.deploy a, li.rs a, li.svc a {
+li.pod button, li.deploy button, li.rs button, li.svc button,
+li.sts button, li.ds button, li.job button, li.cronjob button, li.cm button,
+li.ing button, li.pvc button, li.hpa button, li.netpol button, li div.name {
 	display: block;
+	width: 100%;
 	padding: 2px;
-	margin: 2px;
+	margin: 0;
+	border: 0;
+	background: none;
+	font-weight: bold;
 	color: white;
 	text-align: center;
+	cursor: crosshair;
+}
+li div.name {
+	cursor: default;
+}
+
+li form {
+	margin: 2px;
 }
 
 li a, li a:hover {
@@ -42,21 +56,64 @@ li a, li a:hover {
 
 li.pod {
 	background-color: #050505;
+	color: white;
 }
 li.deploy {
 	background-color: #ffc674;
 }
+div.details {
+	text-align: center;
+	font-size: 80%;
+	padding-bottom: 2px;
+}
+div.details form {
+	display: inline;
+}
+div.details a {
+	cursor: pointer;
+	text-decoration: underline;
+}
+div.details button {
+	padding: 0 6px;
+	border: 0;
+	border-radius: 2px;
+	background-color: rgba(255, 255, 255, 0.6);
+}
 li.rs {
 	background-color: #f0a9d5
 }
 li.svc {
 	background-color: #dcea64;
 }
+li.sts {
+	background-color: #7fb3d5;
+}
+li.ds {
+	background-color: #76d7c4;
+}
+li.job {
+	background-color: #bb8fce;
+}
+li.cronjob {
+	background-color: #a569bd;
+}
+li.cm {
+	background-color: #aab7b8;
+}
+li.ing {
+	background-color: #f1948a;
+}
+li.pvc {
+	background-color: #85929e;
+}
+li.hpa {
+	background-color: #f8c471;
+}
+li.netpol {
+	background-color: #5d6d7e;
+}
 table td { word-wrap:break-word; }
 </style>
-{{ if .PageRefresh }}
-<meta http-equiv="refresh" content="2">
-{{ end }}
 </head>
 
 <body>
@@ -65,9 +122,11 @@ table td { word-wrap:break-word; }
 
 <div class="col-sm-6">
 
+<div id="live-hits">{{ block "hits" . }}
 {{ if .Hits }}
 <div class="alert alert-info">This worker returned page <strong>{{ .Hits }}</strong> times.</div>
 {{ end }}
+{{ end }}</div>
 
 
 <div class="alert alert-info">Metrics exported at <a href="/metrics">/metrics</a></div>
@@ -83,6 +142,10 @@ table td { word-wrap:break-word; }
 <div class="alert alert-danger">Redis connection failed: <code>{{ .RedisError }}</code></div>
 {{ end }}
 
+{{ range .Clients }}{{ if not .Healthy }}
+<div class="alert alert-danger">Client <code>{{ .Name }}</code> unhealthy since <code>{{ .Since.Format "2006-01-02 15:04:05" }}</code>: <code>{{ .LastError }}</code>{{ if not .Retry.IsZero }}, next attempt at <code>{{ .Retry.Format "15:04:05" }}</code>{{ end }}</div>
+{{ end }}{{ end }}
+
 {{ if .Cmd }}
 <div class="alert alert-info">Started with command <code>{{ .Cmd }}</code></div>
 {{ end }}
@@ -92,34 +155,168 @@ table td { word-wrap:break-word; }
 {{ end }}
 
 
+{{ if .LoadJobs }}
+<div class="alert alert-warning">
+CPU load jobs:<br>
+<ul>
+{{ range .LoadJobs }}
+	<li>Job <code>{{ .ID }}</code> - {{ .Cores }} cores at {{ .Percent }}%, {{ .Remaining }} remaining</li>
+{{ end }}
+</ul>
+</div>
+{{ end }}
+
+{{ if or .Memory.Held .Memory.Rate }}
+<div class="alert alert-warning">Holding <code>{{ .Memory.HeldHuman }}</code> of memory{{ if .Memory.Rate }}, growing by <code>{{ .Memory.RateHuman }}</code> per second{{ end }}{{ if .Memory.Limit }}, container limit is <code>{{ .Memory.LimitHuman }}</code>{{ end }}.</div>
+{{ end }}
+
+{{ if or .Disk.Filled .Disk.IORunning }}
+<div class="alert alert-warning">
+Data directory <code>{{ .Disk.Dir }}</code> filled with <code>{{ .Disk.FilledHuman }}</code>{{ if .Disk.IORunning }}, I/O stress running at <code>{{ .Disk.WriteRateHuman }}/s</code> write and <code>{{ .Disk.ReadRateHuman }}/s</code> read{{ end }}.
+</div>
+{{ end }}
+
+{{ if .Disk.LastError }}
+<div class="alert alert-danger">Disk stress failed: <code>{{ .Disk.LastError }}</code></div>
+{{ end }}
+
+<div id="live-faults">{{ block "faults" . }}
+{{ if .Faults }}
+<div class="alert alert-warning">
+Injected faults:<br>
+<table class="table table-sm">
+<thead>
+<tr><th>Route</th><th>Probability</th><th>Status</th><th>Latency</th><th>Body</th></tr>
+</thead>
+<tbody>
+{{ range .Faults }}
+<tr><td><code>{{ .Route }}</code></td><td>{{ .Probability }}</td><td>{{ .Status }}</td><td>{{ .Latency }}</td><td>{{ .Body }}</td></tr>
+{{ end }}
+</tbody>
+</table>
+</div>
+{{ end }}
+{{ end }}</div>
+
+<div id="live-chaos">{{ block "chaos" . }}
+{{ if or .Chaos.Running .Chaos.Audit }}
+<div class="alert {{ if .Chaos.Running }}alert-danger{{ else }}alert-secondary{{ end }}">
+{{ if .Chaos.Running }}
+Chaos started from <code>{{ .Chaos.Source }}</code> at <code>{{ .Chaos.Started.Format "2006-01-02 15:04:05" }}</code> is killing <code>{{ .Chaos.Config }}</code>{{ if .Chaos.Config.DryRun }} <span class="badge bg-secondary">dry run</span>{{ end }}{{ if not .Chaos.Next.IsZero }}, next round at <code>{{ .Chaos.Next.Format "15:04:05" }}</code>{{ end }}.<br>
+{{ if .Chaos.Follower }}Rounds are skipped on this replica, pods are killed by leader{{ with .Chaos.Leader }} <code>{{ . }}</code>{{ end }}.<br>{{ end }}
+{{ else }}
+Chaos is stopped.<br>
+{{ end }}
+{{ with .Chaos.Audit }}
+<table class="table table-sm">
+<thead>
+<tr><th>Time</th><th>Pod</th><th>Result</th><th>Message</th></tr>
+</thead>
+<tbody>
+{{ range . }}
+<tr><td>{{ .Time.Format "15:04:05" }}</td><td>{{ if .Pod }}<code>{{ .Namespace }}/{{ .Pod }}</code>{{ else }}{{ .Namespace }}{{ end }}</td><td><span class="badge {{ if eq .Result "killed" }}bg-danger{{ else if eq .Result "failed" }}bg-warning{{ else }}bg-secondary{{ end }}">{{ .Result }}</span></td><td>{{ .Message }}</td></tr>
+{{ end }}
+</tbody>
+</table>
+{{ end }}
+</div>
+{{ end }}
+{{ end }}</div>
+
 {{ if .ConfFile }}
-<div class="alert alert-info">Config file <code>/etc/kad/config.yml</code> content:<br><code><pre>{{ .ConfFile }}<pre></code></div>
+<div class="alert alert-info">Config file <code>{{ .ConfigFilePath }}</code> content:<br><code><pre>{{ .ConfFile }}<pre></code></div>
 {{ else }}
-<div class="alert alert-warning">Config file <code>/etc/kad/config.yml</code> is empty.</code></div>
+<div class="alert alert-warning">Config file <code>{{ .ConfigFilePath }}</code> is empty.</code></div>
+{{ end }}
+
+{{ if .ConfigReloadError }}
+<div class="alert alert-danger">Config reload failed: <code>{{ .ConfigReloadError }}</code></div>
+{{ end }}
+
+{{ if not .ConfigReloaded.IsZero }}
+<div class="alert alert-info">Config reloaded at <code>{{ .ConfigReloaded.Format "2006-01-02 15:04:05" }}</code></div>
+{{ end }}
+
+{{ if .Settings }}
+<div class="alert alert-info">
+Effective configuration (flag &gt; env &gt; file &gt; default):<br>
+<table class="table table-sm">
+<thead>
+<tr><th>Name</th><th>Value</th><th>Source</th></tr>
+</thead>
+<tbody>
+{{ range .Settings }}
+<tr><td>{{ .Name }}</td><td><code>{{ .Value }}</code></td><td>{{ .Source }}</td></tr>
+{{ end }}
+</tbody>
+</table>
+</div>
 {{ end }}
 
+<div id="live-ready">{{ block "ready" . }}
 {{ if not .Ready }}
 <div class="alert alert-danger">This replica isn't ready.</div>
 {{ end }}
+{{ with .Leader }}{{ if .Enabled }}
+{{ if .Leader }}
+<div class="alert alert-success">This replica <code>{{ .Identity }}</code> is leader of lease <code>{{ .Lease }}</code> since <code>{{ .Since.Format "2006-01-02 15:04:05" }}</code>.</div>
+{{ else if .Holder }}
+<div class="alert alert-info">Leader of lease <code>{{ .Lease }}</code> is <code>{{ .Holder }}</code> since <code>{{ .Since.Format "2006-01-02 15:04:05" }}</code>, this replica <code>{{ .Identity }}</code> is follower.</div>
+{{ else if .Error }}
+<div class="alert alert-danger">Unable to join election of lease <code>{{ .Lease }}</code>, this replica is follower until it joins: {{ .Error }}</div>
+{{ else }}
+<div class="alert alert-warning">Leader of lease <code>{{ .Lease }}</code> isn't known yet.</div>
+{{ end }}
+{{ end }}{{ end }}
+{{ end }}</div>
 
 
 {{ if .KubernetesError }}
 <div class="alert alert-warning">Failed accesing Kubernetes: <code>{{ .KubernetesError }}</code></div>
 {{ end }}
 
-{{ if .PersistentFiles }}
+{{ if .DataDir }}
 <div class="alert alert-info">
-Persistent files:<br>
+{{ if .PersistentFiles }}
+Persistent files in <code>{{ .DataDir }}</code>:<br>
+<table class="table table-sm">
+<thead>
+<tr><th>Name</th><th>Size</th><th>Modified</th></tr>
+</thead>
+<tbody>
+{{ range .PersistentFiles }}
+{{ if .Dir }}
+<tr><td><code>{{ .Name }} (d)</code></td><td></td><td>{{ .Modified.Format "2006-01-02 15:04:05" }}</td></tr>
+{{ else }}
+<tr><td><a href="/data/{{ .Name }}"><code>{{ .Name }}</code></a></td><td>{{ .SizeHuman }}</td><td>{{ .Modified.Format "2006-01-02 15:04:05" }}</td></tr>
+{{ end }}
+{{ end }}
+</tbody>
+</table>
+
+Pod markers:<br>
 <ul>
-{{ with .PersistentFiles }}
-{{ range . }}
-	<li><code>{{ . }}</code></li>
+{{ range .PersistentFiles }}
+{{ if .Marker }}
+	<li><code>{{ .Marker }}</code>{{ if gt (len .Starts) 1 }}, started {{ len .Starts }} times, last <code>{{ .LastStart }}</code>{{ end }}</li>
 {{ end }}
 {{ end }}
 </ul>
+{{ else }}
+Data directory <code>{{ .DataDir }}</code> is empty or missing.<br>
+{{ end }}
+
+<form method="post" enctype="multipart/form-data" onsubmit="if (!this.file.files.length) return false; this.action='/data/' + encodeURIComponent(this.file.files[0].name)">
+<input type="hidden" name="csrf" value="{{ .CSRFToken }}">
+{{ if .ActionsToken }}Token: <input type="password" name="token"> {{ end }}<input type="file" name="file"> <input type="submit" value="Upload">
+</form>
 </div>
 {{ end }}
 
+{{ if .Revealed }}
+<div class="alert alert-danger">Secret values are revealed on admin port.</div>
+{{ end }}
+
 {{ if ne .RemoteAddr "" }}
 <div class="alert alert-info">Remote address: <code>{{ .RemoteAddr }}</code></div>
 {{ end }}
@@ -128,32 +325,62 @@ Persistent files:<br>
 <div class="doc">
 <b>Endpoints (port {{ .Vars.listen.Value }}):</b>
 <ul>
-	<li><a>/heavy</a> - run many parallel goroutines printing /dev/null</li>
-	<li><a>/slow</a> - wait 3 second before server reply</li>
+	<li><a>/heavy</a> - generate CPU load with <code>POST</code>, use <code>?cores=2&amp;percent=50&amp;duration=5m</code> to control it (default all cores at 100% for 1m), cores of all jobs are limited to number of CPUs</li>
+	<li><a>/memory</a> - allocate and hold memory, use <code>?size=100Mi</code> or <code>?fraction=0.5</code> (of cgroup limit) and <code>?rate=10Mi</code> to leak memory every second</li>
+	<li><a>/disk/fill</a> - write file of <code>?size=1Gi</code> into data directory</li>
+	<li><a>/disk/io</a> - write and read file of <code>?size=64Mi</code> (max 1Gi) in loop for <code>?duration=1m</code></li>
+	<li><a>/data</a> - list data directory, <code>GET</code>, <code>PUT</code> and <code>DELETE</code> on <code>/data/{name}</code> download, upload and delete files (guarded like Kubernetes actions, page upload needs CSRF token, <code>actions.token</code> is required when set), <code>POST /data/verify?size=1Mi</code> writes file and verifies its checksum</li>
+	<li><a>/slow</a> - wait 3 second before server reply, use <code>?ms=250&amp;jitter=50&amp;dist=normal</code> to set latency (max 60s)</li>
 	<li><a>/check/live</a> - liveness probe, always OK</li>
 	<li><a>/check/ready</a> - readiness probo, ready if file <code>/tmp/notready</code> doesn't exist</li>
 	<li><a>/metrics</a> - <a href="https://prometheus.io/">Prometheus</a> metrics</li>
-	<li><a>/hostname</a> - prints hostname
+	<li><a>/peers</a> - table of all replicas (hostname, node, color, version, ready, hits, uptime) found by endpoints of <code>--peers-service</code> or headless DNS name <code>--peers-dns</code>, JSON for <code>Accept: application/json</code>, peers are read at most every 5 seconds</li>
+	<li><a>/hostname</a> - prints hostname, with leader election it tells if this replica is leader
+	<li><a>/api/v1/info</a> - everything shown on this page as JSON, <code>/api/v1/info/{section}</code> returns one section (e.g. <code>hits</code>, <code>vars</code>, <code>kubernetes</code>, <code>instance</code>), page hit isn't counted; <code>/</code> returns the same JSON for <code>Accept: application/json</code></li>
+	<li><a>/kubernetes/delete/{type}/{name}</a> - delete pod, deploy, rs or svc (sts, ds, job, cronjob, cm, ing, pvc, hpa and netpol when listed in <code>actions.allowedTypes</code> of config file), <code>POST</code> from this page (with CSRF token) or <code>DELETE</code>, optionally with <code>Authorization: Bearer</code> token, see <code>actions</code> in config file, <code>namespace</code> query parameter selects watched namespace</li>
+	<li><a>/api/v1/deployments/{name}/{action}</a> - rollout action on deployment, <code>POST</code> JSON body, actions are <code>scale</code> (<code>{"replicas": 3}</code> or <code>{"delta": -1}</code>), <code>restart</code>, <code>pause</code>, <code>resume</code>, <code>image</code> (<code>{"image": "nginx:1.25"}</code>) and <code>color</code> (<code>{"color": "green"}</code>), <code>container</code> selects container when deployment has more of them, <code>namespace</code> query parameter selects watched namespace</li>
+	<li><a>/kubernetes/logs/{pod}</a> - log viewer of pod, <code>namespace</code>, <code>container</code>, <code>previous=true</code> (previous container) and <code>tailLines</code> (default 100) select logs, raw stream is at <code>/kubernetes/logs/{pod}/stream</code> with same query as server-sent events</li>
+	<li><a>/events</a> - stream of page updates (hits, readiness and leader, faults, chaos and Kubernetes resources) as server-sent events, used by this page to update in place</li>
 </ul>
 
 <b>Admin endpoints (port {{ .Vars.listenAdmin.Value }}):</b>
 <ul>
-	<li><a>/action/terminate</a> - Disable readiness probe, wait 15s and exit</li>
+	<li><a>/</a>, <a>/api/v1/info</a> - this page and JSON API, secret values are unmasked when <code>masking.revealOnAdmin</code> is enabled</li>
+	<li><a>/action/terminate</a> - Disable readiness probe, drain for exit delay and exit (same as <code>SIGTERM</code>)</li>
 	<li><a>/check/live</a> - liveness probe, always OK</li>
 	<li><a>/check/ready</a> - readiness probo, ready if file <code>/tmp/notready</code> doesn't exist</li>
+	<li><a>/heavy</a> - <code>GET</code> lists CPU load jobs, <code>DELETE</code> cancels them (<code>?id=</code> cancels one job)</li>
+	<li><a>/memory</a> - <code>GET</code> shows held memory, <code>DELETE</code> releases it and stops leaking</li>
+	<li><a>/disk</a> - <code>GET</code> shows disk stress status, <code>DELETE</code> stops I/O stress and removes written files, <code>DELETE /disk/io</code> stops I/O stress only</li>
+	<li><a>/api/v1/instance</a> - hostname, color, version, readiness and hits of this instance as JSON, read by <code>/peers</code> of other replicas without latency and faults of client port</li>
+	<li><a>/faults</a> - fault injection, <code>GET</code> lists faults, <code>PUT</code> sets fault for route (exact route wins over patterns, then pattern with longest prefix before wildcard), <code>DELETE</code> resets faults</li>
+	<li><a>/chaos</a> - pod killer, <code>GET</code> shows status and audit trail, <code>PUT</code> starts it with JSON body with required <code>labelSelector</code> (e.g. <code>{"labelSelector": "app=kad", "interval": "1m", "count": 1, "minAvailable": 1, "dryRun": true}</code>, <code>rate</code> of rounds per minute at random times replaces <code>interval</code>, <code>percent</code> of pods replaces <code>count</code>), <code>DELETE</code> stops it; pods must be allowed by <code>actions</code> in config file</li>
 	<li><a>/malware</a> - malware endpoint, exposes all cluster secrets and environment variables</li>
 </ul>
 
 <b>Command options:</b>
 <ul>
-	<li><a>--color</a> - Set background color</li>
+	<li><a>--config</a> - Path to configuration file</li>
+	<li><a>--color</a> - Set background color, <code>COLOR</code> takes precedence</li>
 	<li><a>--fail</a> - Terminate with non-zero exit code (immediatelly)</li>
 	<li><a>--failure-probability</a> - Request to / will be failing with this probability</li>
+	<li><a>--exit-delay</a> - Drain period in seconds, instance reports not ready but keeps serving before shutdown</li>
+	<li><a>--shutdown-timeout</a> - Time in seconds to wait for in-flight requests on shutdown</li>
+	<li><a>--confirm-actions</a> - Ask for confirmation before deleting resources from this page</li>
+	<li><a>--namespace</a> - Namespace of actions without <code>namespace</code> parameter, default is namespace of service account or <code>kad</code> outside of cluster (<code>NAMESPACE</code>)</li>
+	<li><a>--namespaces</a> - Comma separated namespaces to watch, default is <code>--namespace</code> (<code>NAMESPACES</code>)</li>
+	<li><a>--namespace-selector</a> - Watch namespaces matching label selector too, e.g. <code>kad=demo</code> (<code>NAMESPACE_SELECTOR</code>)</li>
+	<li><a>--leader-elect</a>, <a>--lease-name</a> - Elect leader of replicas with <code>coordination.k8s.io</code> Lease (default <code>kad</code> in <code>--namespace</code>), chaos loop runs only on leader (<code>LEADER_ELECTION</code>, <code>LEASE_NAME</code>)</li>
+	<li><a>--peers-service</a>, <a>--peers-dns</a> - Find replicas of kad by endpoints of service or by resolving headless service name, <code>peers.port</code> is their admin port (default same as <code>listenAdmin</code>) (<code>PEERS_SERVICE</code>, <code>PEERS_DNS</code>)</li>
+	<li><a>--chaos</a>, <a>--chaos-dry-run</a> - Kill pods matching required <code>chaos.labelSelector</code> (<code>CHAOS_LABEL_SELECTOR</code>) every <code>chaos.interval</code> (default 5m) while <code>chaos.minAvailable</code> (default 1) ready pods are left, dry run only records them (<code>CHAOS_ENABLED</code>)</li>
+	<li><a>--mask-mode</a> - Masking of secret environment variables, headers and config file values (<code>redact</code>, <code>partial</code>, <code>hash</code> with key generated on start or <code>off</code>), rules are set in <code>masking.rules</code> of config file</li>
+	<li><a>--latency-distribution</a>, <a>--latency-ms</a>, <a>--latency-jitter</a> - Latency (max 60s) added to every request except probes and metrics (<code>fixed</code>, <code>uniform</code>, <code>normal</code> or <code>longtail</code>), query parameters <code>ms</code>, <code>jitter</code>, <code>dist</code>, <code>tail</code> and <code>tailMs</code> of <code>/slow</code> override it per request</li>
 </ul>
 
 
 <p>
 Server is expecting configuration file <code>{{ .ConfigFilePath }}</code>. It will run without configuration but error mesage will be printed.
+File is watched for changes and <code>color</code>, <code>failureProbability</code>, <code>latency</code>, <code>ready</code>, <code>redis</code>, <code>dataDir</code>, <code>masking</code>, <code>actions</code>, <code>chaos</code>, <code>exitDelay</code> and <code>shutdownTimeout</code> are applied without restart.
 </p>
 
 <p>
@@ -167,43 +394,111 @@ Contribution is apprecited at <a href="https://gitlab.com/6shore.net/kad">gitlab
 </div>
 <div class="col-sm-6">
 
+<div id="live-kubernetes">{{ block "kubernetes" . }}
 {{ if not .KubernetesError }}
 <div class="doc">
 
 <p>
 Kubernetes at <a href="{{ .KubernetesHost }}">{{ .KubernetesHost }}</a>
+{{ if .KubernetesSynced }}
+<span class="badge bg-success">cache synced</span>
+{{ else }}
+<span class="badge bg-warning">cache syncing</span>
+{{ end }}
 </p>
 
-Pods
+{{ range $ns := .Resources.Namespaces }}
+{{ with $.Resources.In $ns }}
+<h6>Namespace {{ $ns }}</h6>
+
+{{ range $l := .Kinds }}
+{{ if $l.Items }}
+{{ $l.Title }}
 <ul>
-{{ range $i := .Resources.Pods }}
-<li class="pod"><a href="/kubernetes/delete/pod/{{ $i.ObjectMeta.Name }}">{{ $i.ObjectMeta.Name }}</a></li>
+{{ range $i := $l.Items }}
+<li class="{{ $l.Name }}">{{ if and $l.Deletable ($.Access.Can $ns "delete" $l.Resource) }}<form method="post" action="/kubernetes/delete/{{ $l.Name }}/{{ $i.Name }}"><input type="hidden" name="csrf" value="{{ $.CSRFToken }}"><input type="hidden" name="namespace" value="{{ $ns }}"><button type="submit">{{ $i.Name }}</button></form>{{ else }}<div class="name">{{ $i.Name }}</div>{{ end }}
+<div class="details">{{ $i.Details }}, {{ age $i.Created }}{{ if and $l.Logs ($.Access.Can $ns "get" (print $l.Resource "/log")) }}, <a href="/kubernetes/logs/{{ $i.Name }}?namespace={{ $ns }}">logs</a>{{ end }}
+{{ with $i.Rollout }}<br>
+{{ if $.Access.Can $ns "update" (print $l.Resource "/scale") }}
+<form method="post" action="/kubernetes/{{ $l.Name }}/{{ $i.Name }}/scale"><input type="hidden" name="csrf" value="{{ $.CSRFToken }}"><input type="hidden" name="namespace" value="{{ $ns }}"><input type="hidden" name="delta" value="-1"><button type="submit" title="Scale down">&minus;</button></form>
+<form method="post" action="/kubernetes/{{ $l.Name }}/{{ $i.Name }}/scale"><input type="hidden" name="csrf" value="{{ $.CSRFToken }}"><input type="hidden" name="namespace" value="{{ $ns }}"><input type="hidden" name="delta" value="1"><button type="submit" title="Scale up">+</button></form>
+{{ end }}
+{{ if $.Access.Can $ns "patch" $l.Resource }}
+<form method="post" action="/kubernetes/{{ $l.Name }}/{{ $i.Name }}/restart"><input type="hidden" name="csrf" value="{{ $.CSRFToken }}"><input type="hidden" name="namespace" value="{{ $ns }}"><button type="submit">restart</button></form>
+{{ if .Paused }}
+<form method="post" action="/kubernetes/{{ $l.Name }}/{{ $i.Name }}/resume"><input type="hidden" name="csrf" value="{{ $.CSRFToken }}"><input type="hidden" name="namespace" value="{{ $ns }}"><button type="submit">resume</button></form>
+{{ else }}
+<form method="post" action="/kubernetes/{{ $l.Name }}/{{ $i.Name }}/pause"><input type="hidden" name="csrf" value="{{ $.CSRFToken }}"><input type="hidden" name="namespace" value="{{ $ns }}"><button type="submit">pause</button></form>
+{{ end }}
+{{ with .Containers }}
+<form method="post" action="/kubernetes/{{ $l.Name }}/{{ $i.Name }}/image" onsubmit="var v = prompt('Image', this.container.selectedOptions[0].dataset.image); if (!v) return false; this.image.value = v;"><input type="hidden" name="csrf" value="{{ $.CSRFToken }}"><input type="hidden" name="namespace" value="{{ $ns }}"><input type="hidden" name="image" value=""><select name="container" title="Container">{{ range . }}<option value="{{ .Name }}" data-image="{{ .Image }}">{{ .Name }}</option>{{ end }}</select><button type="submit">image</button></form>
+<form method="post" action="/kubernetes/{{ $l.Name }}/{{ $i.Name }}/color" onsubmit="var v = prompt('COLOR', this.color.value); if (!v) return false; this.color.value = v;"><input type="hidden" name="csrf" value="{{ $.CSRFToken }}"><input type="hidden" name="namespace" value="{{ $ns }}"><input type="hidden" name="color" value=""><select name="container" title="Container">{{ range . }}<option value="{{ .Name }}">{{ .Name }}</option>{{ end }}</select><button type="submit">color</button></form>
+{{ end }}
+{{ end }}
+{{ end }}
+</div>
+</li>
 {{ end }}
 </ul> 
+{{ end }}
+{{ end }}
 
-Deployments
-<ul>
-{{ range $i := .Resources.Deployments }}
-<li class="deploy"><a href="/kubernetes/delete/deploy/{{ $i.ObjectMeta.Name }}">{{ $i.ObjectMeta.Name }}</a></li>
+{{ if .Events }}
+Events
+<table class="table table-sm">
+<thead>
+<tr><th>Age</th><th>Type</th><th>Reason</th><th>Object</th><th>Message</th></tr>
+</thead>
+<tbody>
+{{ range $e := .Events }}
+<tr class="{{ if eq $e.Type "Warning" }}table-warning{{ end }}"><td>{{ age (eventTime $e) }}</td><td>{{ $e.Type }}</td><td>{{ $e.Reason }}{{ if gt $e.Count 1 }} (x{{ $e.Count }}){{ end }}</td><td>{{ $e.InvolvedObject.Kind }}/{{ $e.InvolvedObject.Name }}</td><td>{{ $e.Message }}</td></tr>
+{{ end }}
+</tbody>
+</table>
 {{ end }}
-</ul> 
 
-ReplicaSets
-<ul>
-{{ range $i := .Resources.ReplicaSets }}
-<li class="rs"><a href="/kubernetes/delete/rs/{{ $i.ObjectMeta.Name }}">{{ $i.ObjectMeta.Name }}</a></li>
 {{ end }}
-</ul> 
+{{ else }}
+<p>No watched namespace.</p>
+{{ end }}
 
-Services
-<ul>
-{{ range $i := .Resources.Services }}
-<li class="svc"><a href="/kubernetes/delete/svc/{{ $i.ObjectMeta.Name }}">{{ $i.ObjectMeta.Name }}</a></li>
+</div>
 {{ end }}
-</ul> 
 
+{{ with .Access }}
+{{ if or .Results .Error }}
+<div class="doc">
+<p>
+Kubernetes access
+{{ if .Error }}
+<span class="badge bg-warning">unknown</span>
+{{ else if .Denied }}
+<span class="badge bg-danger">limited</span>
+{{ else }}
+<span class="badge bg-success">allowed</span>
+{{ end }}
+</p>
+{{ if .Error }}
+<code>{{ .Error }}</code>
+{{ else }}
+{{ $a := . }}
+{{ range $ns := $a.Namespaces }}
+<table class="table table-sm">
+<thead>
+<tr><th>{{ $ns }}</th>{{ range $v := $a.Verbs }}<th>{{ $v }}</th>{{ end }}</tr>
+</thead>
+<tbody>
+{{ range $r := $a.Resources }}
+<tr><td>{{ $r }}</td>{{ range $v := $a.Verbs }}<td>{{ with $a.Result $ns $v $r }}{{ if .Allowed }}<span class="badge bg-success">yes</span>{{ else }}<span class="badge bg-danger" title="{{ .Reason }}">no</span>{{ end }}{{ end }}</td>{{ end }}</tr>
+{{ end }}
+</tbody>
+</table>
+{{ end }}
+{{ end }}
 </div>
 {{ end }}
+{{ end }}
+{{ end }}</div>
 
 <table class="table table-hover">
 <thead>
@@ -211,7 +506,7 @@ Services
 </thead>
 <tbody>
 {{ range $v := .Vars }}
-<tr class="{{ if $v.Dangerous }}table-danger{{ end }}"><td>{{ $v.Name }}</td><td>{{ $v.Value }}</td></tr>
+<tr class="{{ if $v.Dangerous }}table-danger{{ end }}"><td>{{ $v.Name }}{{ if $v.Rule }} <span class="badge bg-danger">{{ $v.Rule }}</span>{{ end }}</td><td>{{ $v.Value }}</td></tr>
 {{ end }}
 </tbody>
 </table>
@@ -227,7 +522,7 @@ Services
 <tbody>
 {{ with .Headers }}
 {{ range . }}
-	<tr><td>{{ .Name }}</td><td>{{ .Value }}</td></tr>
+	<tr class="{{ if .Rule }}table-danger{{ end }}"><td>{{ .Name }}{{ if .Rule }} <span class="badge bg-danger">{{ .Rule }}</span>{{ end }}</td><td>{{ .Value }}</td></tr>
 {{ end }}
 {{ end }}
 
@@ -237,6 +532,18 @@ Services
 
 </div> <!-- container -->
 
+<script>
+// replace page blocks with versions pushed by server
+if (window.EventSource) {
+	var events = new EventSource("/events");
+	["hits", "ready", "faults", "chaos", "kubernetes"].forEach(function(name) {
+		events.addEventListener(name, function(e) {
+			document.getElementById("live-" + name).innerHTML = e.data;
+		});
+	});
+}
+</script>
+
 </body>
 </html>
 `